@@ -0,0 +1,192 @@
+// Package advisory reads security advisory databases: JSON files, local or
+// served over HTTP(S), listing module versions with known vulnerabilities.
+//
+//	{
+//	  "advisories": [{
+//	    "id": "ACE-2026-0001",
+//	    "module": "https://github.com/acidlang/json",
+//	    "summary": "Stack overflow on deeply nested input",
+//	    "severity": "high",
+//	    "ranges": [{"introduced": "v1.0.0", "fixed": "v1.2.3"}],
+//	    "commits": ["<affected commit hash>"],
+//	    "url": "https://example.org/ACE-2026-0001"
+//	  }]
+//	}
+//
+// A module is a repository URL in any spelling, or a module name. Ranges are
+// half open: versions from introduced ("0" or empty for all) up to, but not
+// including, fixed (empty when there is no fix yet).
+package advisory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acidlang/ace/semver"
+	"github.com/acidlang/ace/source"
+)
+
+// A range of affected versions.
+type Range struct {
+	Introduced string `json:"introduced"`
+	Fixed      string `json:"fixed"`
+}
+
+// A vulnerability affecting some versions or commits of a module.
+type Advisory struct {
+	ID       string   `json:"id"`
+	Module   string   `json:"module"`
+	Summary  string   `json:"summary"`
+	Severity string   `json:"severity"`
+	Ranges   []Range  `json:"ranges"`
+	Commits  []string `json:"commits"`
+	URL      string   `json:"url"`
+}
+
+// An advisory database.
+type Database struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+// Severities from least to most serious.
+var severities = []string{"low", "moderate", "high", "critical"}
+
+// Load a database from a local path, a file:// URL or an http(s):// URL,
+// giving up on a download once timeout has passed, unless it is zero.
+func Load(location string, timeout time.Duration) (Database, error) {
+	var db Database
+
+	content, err := read(location, timeout)
+	if err != nil {
+		return db, fmt.Errorf("could not read advisory database %s: %v", source.Redact(location), err)
+	}
+	if err := json.Unmarshal(content, &db); err != nil {
+		return db, fmt.Errorf("invalid advisory database %s: %v", source.Redact(location), err)
+	}
+
+	for i, advisory := range db.Advisories {
+		if err := advisory.check(); err != nil {
+			return db, fmt.Errorf("invalid advisory database %s: advisory %d: %v", source.Redact(location), i+1, err)
+		}
+		db.Advisories[i].Severity = strings.ToLower(advisory.Severity)
+	}
+	return db, nil
+}
+
+func read(location string, timeout time.Duration) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("%s", source.Redact(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", source.Redact(location), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (a Advisory) check() error {
+	if a.ID == "" || a.Module == "" {
+		return fmt.Errorf("id and module are required")
+	}
+	if len(a.Ranges) == 0 && len(a.Commits) == 0 {
+		return fmt.Errorf("%s lists no affected ranges or commits", a.ID)
+	}
+	if a.Severity != "" && !slices.Contains(severities, strings.ToLower(a.Severity)) {
+		return fmt.Errorf("%s has unknown severity '%s', expected one of %s", a.ID, a.Severity, strings.Join(severities, ", "))
+	}
+	for _, r := range a.Ranges {
+		if r.Introduced != "" && r.Introduced != "0" && !semver.Valid(r.Introduced) {
+			return fmt.Errorf("%s: introduced version '%s' is not a semantic version", a.ID, r.Introduced)
+		}
+		if r.Fixed != "" && !semver.Valid(r.Fixed) {
+			return fmt.Errorf("%s: fixed version '%s' is not a semantic version", a.ID, r.Fixed)
+		}
+	}
+	return nil
+}
+
+// Check whether an advisory is about the module locked under moduleName from
+// repoURL.
+func (a Advisory) Matches(moduleName, repoURL string) bool {
+	if source.Same(a.Module, repoURL) {
+		return true
+	}
+	return !strings.ContainsAny(a.Module, "/:") && a.Module == moduleName
+}
+
+// Check whether a version, if known, or a commit is affected.
+func (a Advisory) Affects(version, commit string) bool {
+	if len(commit) >= 7 {
+		for _, affected := range a.Commits {
+			if len(affected) >= 7 && (strings.HasPrefix(commit, affected) || strings.HasPrefix(affected, commit)) {
+				return true
+			}
+		}
+	}
+
+	v, ok := semver.Parse(version)
+	if !ok {
+		return false
+	}
+	for _, r := range a.Ranges {
+		if introduced, ok := semver.Parse(r.Introduced); ok && semver.Compare(v, introduced) < 0 {
+			continue
+		}
+		if fixed, ok := semver.Parse(r.Fixed); ok && semver.Compare(v, fixed) >= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Get the versions that fix the advisory, lowest first.
+func (a Advisory) FixedVersions() []string {
+	var fixed []string
+	for _, r := range a.Ranges {
+		if r.Fixed != "" && !slices.Contains(fixed, r.Fixed) {
+			fixed = append(fixed, r.Fixed)
+		}
+	}
+	SortVersions(fixed)
+	return fixed
+}
+
+// Get the advisories about a module.
+func (db Database) For(moduleName, repoURL string) []Advisory {
+	var matching []Advisory
+	for _, advisory := range db.Advisories {
+		if advisory.Matches(moduleName, repoURL) {
+			matching = append(matching, advisory)
+		}
+	}
+	return matching
+}
+
+// Rank a severity for sorting, higher being more serious. Advisories
+// without one rank lowest.
+func SeverityRank(severity string) int {
+	return slices.Index(severities, severity) + 1
+}
+
+// Sort semantic versions in ascending order of precedence.
+func SortVersions(versions []string) {
+	slices.SortFunc(versions, func(a, b string) int {
+		x, _ := semver.Parse(a)
+		y, _ := semver.Parse(b)
+		return semver.Compare(x, y)
+	})
+}
@@ -0,0 +1,314 @@
+// Package archive downloads module sources published as .tar.gz or .zip
+// archives and extracts them safely.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acidlang/ace/source"
+)
+
+// The most an archive may extract to, guarding against decompression bombs.
+var MaxSize int64 = 1 << 30
+
+// Reported when a downloaded archive does not match its expected checksum.
+var ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+var extensions = []string{".tar.gz", ".tgz", ".zip"}
+
+// Check whether a URL points at a module archive rather than a git repository:
+// an http(s):// or file:// URL ending in .tar.gz, .tgz or .zip.
+func IsArchive(url string) bool {
+	scheme, _, found := strings.Cut(url, "://")
+	if !found || !strings.EqualFold(scheme, "http") && !strings.EqualFold(scheme, "https") && !strings.EqualFold(scheme, "file") {
+		return false
+	}
+	return extension(url) != ""
+}
+
+// Get the archive's file name without its extension, e.g. "pkg-1.2.0" for
+// https://host/pkg-1.2.0.tar.gz.
+func Name(url string) string {
+	base := path.Base(trimQuery(url))
+	return base[:len(base)-len(extension(url))]
+}
+
+// Downloads archives. The zero value applies no rewrite rules and lets
+// downloads take as long as they need.
+type Client struct {
+	// How long a download may take, no limit when zero.
+	Timeout time.Duration
+	// Rewrite rules applied to archive URLs before downloading them.
+	Rewrites source.Rewrites
+}
+
+// Download the archive at url and extract it into dir, which must not exist.
+//
+// When sum is set, the downloaded file must have that SHA-256, given in hex
+// with an optional "sha256:" prefix. A single top-level directory, as most
+// release archives have, is stripped.
+func (c Client) Fetch(url, dir, sum string) error {
+	file, err := os.CreateTemp(filepath.Dir(dir), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	if err := c.download(c.Rewrites.Apply(url), io.MultiWriter(file, hash)); err != nil {
+		return err
+	}
+
+	if sum != "" {
+		want := strings.ToLower(strings.TrimPrefix(sum, "sha256:"))
+		if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+			return fmt.Errorf("%w: %s has sha256:%s, expected sha256:%s", ErrChecksumMismatch, source.Redact(url), got, want)
+		}
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(dir), ".extract-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if extension(url) == ".zip" {
+		err = extractZip(file, staging)
+	} else {
+		err = extractTarGz(file, staging)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid archive: %v", err)
+	}
+
+	return os.Rename(topLevel(staging), dir)
+}
+
+func (c Client) download(url string, w io.Writer) error {
+	if scheme, rest, _ := strings.Cut(url, "://"); strings.EqualFold(scheme, "file") {
+		in, err := os.Open(rest)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(w, in)
+		return err
+	}
+
+	client := http.Client{Timeout: c.Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return errors.New(source.Redact(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", source.Redact(url), resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func extractTarGz(file *os.File, dir string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	budget := MaxSize
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			target, err := entryPath(dir, header.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(dir, header.Name, header.FileInfo().Mode(), reader, &budget); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+		default:
+			return fmt.Errorf("%s is a link or special file, which module archives may not contain", header.Name)
+		}
+	}
+}
+
+func extractZip(file *os.File, dir string) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	reader, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	budget := MaxSize
+	for _, entry := range reader.File {
+		mode := entry.Mode()
+		if mode.IsDir() {
+			target, err := entryPath(dir, entry.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			return fmt.Errorf("%s is a link or special file, which module archives may not contain", entry.Name)
+		}
+
+		in, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(dir, entry.Name, mode, in, &budget)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the files in dir to w as a zip archive, leaving out git metadata.
+//
+// Entries are written in lexical order, so the same files always produce the
+// same archive.
+func WriteZip(dir string, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+
+		out, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(out, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Write one archive entry under dir, spending its size from budget.
+func writeFile(dir, name string, mode os.FileMode, content io.Reader, budget *int64) error {
+	target, err := entryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, io.LimitReader(content, *budget+1))
+	*budget -= written
+	if err != nil {
+		return err
+	}
+	if *budget < 0 {
+		return fmt.Errorf("archive extracts to more than %d bytes", MaxSize)
+	}
+	return nil
+}
+
+// Resolve an entry name inside dir, refusing names that would escape it
+// (zip slip), such as "../x" or "/etc/x".
+func entryPath(dir, name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("entry %s would be extracted outside the module directory", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// Get the directory holding an extracted archive's files: its only
+// top-level directory when it has one and nothing else, otherwise dir.
+func topLevel(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+func extension(url string) string {
+	url = strings.ToLower(trimQuery(url))
+	for _, ext := range extensions {
+		if strings.HasSuffix(url, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func trimQuery(url string) string {
+	url, _, _ = strings.Cut(url, "#")
+	url, _, _ = strings.Cut(url, "?")
+	return url
+}
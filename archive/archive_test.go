@@ -0,0 +1,274 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An archive entry: a file with content, a directory (name ending in "/"),
+// or a symbolic link to target.
+type entry struct {
+	name    string
+	content string
+	target  string
+}
+
+func tarGz(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case e.target != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, e.target, 0
+		case strings.HasSuffix(e.name, "/"):
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipFile(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.content
+		switch {
+		case e.target != "":
+			header.SetMode(os.ModeSymlink | 0777)
+			content = e.target
+		case strings.HasSuffix(e.name, "/"):
+			header.SetMode(os.ModeDir | 0755)
+		default:
+			header.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Serve content at /<name> and fetch it into a fresh directory.
+func fetch(t *testing.T, name string, content []byte, sum string) (string, error) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+name {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "module")
+	return dir, Client{}.Fetch(server.URL+"/"+name, dir, sum)
+}
+
+func TestFetchStripsTopLevelDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive func(*testing.T, ...entry) []byte
+	}{
+		{"json-1.0.0.tar.gz", tarGz},
+		{"json-1.0.0.zip", zipFile},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := test.archive(t,
+				entry{name: "json-1.0.0/"},
+				entry{name: "json-1.0.0/module.acidcfg", content: `{"name": "json", "version": "1.0.0"}`},
+				entry{name: "json-1.0.0/src/json.acid", content: "// json"},
+			)
+			dir, err := fetch(t, test.name, content, "")
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "src", "json.acid"))
+			if err != nil || string(got) != "// json" {
+				t.Fatalf("src/json.acid = %q, %v; want the archived file with the top-level directory stripped", got, err)
+			}
+		})
+	}
+}
+
+func TestFetchKeepsSeveralTopLevelEntries(t *testing.T) {
+	content := tarGz(t,
+		entry{name: "module.acidcfg", content: "{}"},
+		entry{name: "src/json.acid", content: "// json"},
+	)
+	dir, err := fetch(t, "json.tgz", content, "")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src", "json.acid")); err != nil {
+		t.Fatalf("src/json.acid missing: %v", err)
+	}
+}
+
+func TestFetchRefusesUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		content func(t *testing.T) []byte
+	}{
+		{"parent.tar.gz", func(t *testing.T) []byte { return tarGz(t, entry{name: "../evil.acid", content: "x"}) }},
+		{"nested-parent.tar.gz", func(t *testing.T) []byte { return tarGz(t, entry{name: "a/../../evil.acid", content: "x"}) }},
+		{"absolute.tar.gz", func(t *testing.T) []byte { return tarGz(t, entry{name: "/tmp/evil.acid", content: "x"}) }},
+		{"backslash.zip", func(t *testing.T) []byte { return zipFile(t, entry{name: `..\evil.acid`, content: "x"}) }},
+		{"parent.zip", func(t *testing.T) []byte { return zipFile(t, entry{name: "../evil.acid", content: "x"}) }},
+		{"symlink.tar.gz", func(t *testing.T) []byte { return tarGz(t, entry{name: "link", target: "/etc/passwd"}) }},
+		{"symlink.zip", func(t *testing.T) []byte { return zipFile(t, entry{name: "link", target: "/etc/passwd"}) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := fetch(t, test.name, test.content(t), "")
+			if err == nil {
+				t.Fatalf("Fetch succeeded, want an error")
+			}
+			if _, statErr := os.Lstat(dir); statErr == nil {
+				t.Errorf("%s was created for a refused archive", dir)
+			}
+			if _, statErr := os.Lstat(filepath.Join(filepath.Dir(dir), "evil.acid")); statErr == nil {
+				t.Errorf("an entry was written outside the module directory")
+			}
+		})
+	}
+}
+
+func TestFetchEnforcesMaxSize(t *testing.T) {
+	defer func(size int64) { MaxSize = size }(MaxSize)
+	MaxSize = 10
+
+	content := tarGz(t, entry{name: "a.acid", content: "123456"}, entry{name: "b.acid", content: "789012"})
+	if _, err := fetch(t, "big.tar.gz", content, ""); err == nil || !strings.Contains(err.Error(), "more than 10 bytes") {
+		t.Fatalf("Fetch = %v, want the size limit error", err)
+	}
+
+	content = tarGz(t, entry{name: "a.acid", content: "12345"}, entry{name: "b.acid", content: "67890"})
+	if _, err := fetch(t, "fits.tar.gz", content, ""); err != nil {
+		t.Fatalf("Fetch of an archive at the limit: %v", err)
+	}
+}
+
+func TestFetchChecksum(t *testing.T) {
+	content := tarGz(t, entry{name: "json.acid", content: "// json"})
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	if _, err := fetch(t, "json.tar.gz", content, "sha256:"+sum); err != nil {
+		t.Fatalf("Fetch with the right checksum: %v", err)
+	}
+	if _, err := fetch(t, "json.tar.gz", content, strings.ToUpper(sum)); err != nil {
+		t.Fatalf("Fetch with an upper case checksum: %v", err)
+	}
+
+	wrong := strings.Repeat("0", 64)
+	dir, err := fetch(t, "json.tar.gz", content, wrong)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Fetch = %v, want ErrChecksumMismatch", err)
+	}
+	if _, statErr := os.Lstat(dir); statErr == nil {
+		t.Errorf("%s was created despite the checksum mismatch", dir)
+	}
+}
+
+func TestFetchReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	err := Client{}.Fetch(server.URL+"/missing.zip", filepath.Join(t.TempDir(), "module"), "")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Fetch = %v, want a 404 error", err)
+	}
+}
+
+func TestWriteZipRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, ".git"), 0755)
+	os.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref"), 0644)
+	os.WriteFile(filepath.Join(src, "module.acidcfg"), []byte(`{"name": "json"}`), 0644)
+	os.MkdirAll(filepath.Join(src, "src"), 0755)
+	os.WriteFile(filepath.Join(src, "src", "json.acid"), []byte("// json"), 0644)
+
+	var first, second bytes.Buffer
+	if err := WriteZip(src, &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteZip(src, &second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("WriteZip is not deterministic")
+	}
+
+	dir, err := fetch(t, "module.zip", first.Bytes(), "")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		t.Errorf(".git was archived")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "src", "json.acid")); string(got) != "// json" {
+		t.Errorf("src/json.acid = %q", got)
+	}
+}
+
+func TestIsArchiveAndName(t *testing.T) {
+	tests := []struct {
+		url     string
+		archive bool
+		name    string
+	}{
+		{"https://example.org/json-1.2.0.tar.gz", true, "json-1.2.0"},
+		{"https://example.org/json-1.2.0.TGZ?token=x", true, "json-1.2.0"},
+		{"file:///srv/json.zip", true, "json"},
+		{"https://github.com/acidlang/json", false, ""},
+		{"/srv/json.zip", false, ""},
+	}
+	for _, test := range tests {
+		if got := IsArchive(test.url); got != test.archive {
+			t.Errorf("IsArchive(%q) = %v, want %v", test.url, got, test.archive)
+		}
+		if test.archive {
+			if got := Name(test.url); got != test.name {
+				t.Errorf("Name(%q) = %q, want %q", test.url, got, test.name)
+			}
+		}
+	}
+}
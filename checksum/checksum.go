@@ -0,0 +1,70 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Hash the contents of a module directory, ignoring git metadata.
+//
+// Every regular file contributes its slash separated relative path and its
+// contents, in sorted order, so the result is the same on every machine.
+// Returns the hash as "sha256:<hex>".
+func Dir(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return Files(dir, files)
+}
+
+// Hash the given files, relative to dir, the same way Dir does.
+func Files(dir string, files []string) (string, error) {
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, name := range files {
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%x  %s\n", fileHash.Sum(nil), name)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Check whether two checksums are equal, tolerating a missing "sha256:" prefix.
+func Equal(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "sha256:"), strings.TrimPrefix(b, "sha256:"))
+}
@@ -0,0 +1,86 @@
+package checksum
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Create a directory holding the given files, keyed by slash separated path.
+func tree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDir(t *testing.T) {
+	base := map[string]string{"module.acidcfg": "{}", "src/json.acid": "// json"}
+	baseSum, err := Dir(tree(t, base))
+	if err != nil {
+		t.Fatalf("Dir: %v", err)
+	}
+	if !strings.HasPrefix(baseSum, "sha256:") || len(baseSum) != len("sha256:")+64 {
+		t.Errorf("Dir = %q, want sha256:<hex>", baseSum)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		same  bool
+	}{
+		{"same files", map[string]string{"module.acidcfg": "{}", "src/json.acid": "// json"}, true},
+		{"git metadata", map[string]string{"module.acidcfg": "{}", "src/json.acid": "// json", ".git/HEAD": "ref: refs/heads/main"}, true},
+		{"changed content", map[string]string{"module.acidcfg": "{}", "src/json.acid": "// yaml"}, false},
+		{"moved file", map[string]string{"module.acidcfg": "{}", "json.acid": "// json"}, false},
+		{"added file", map[string]string{"module.acidcfg": "{}", "src/json.acid": "// json", "README": ""}, false},
+		{"removed file", map[string]string{"module.acidcfg": "{}"}, false},
+	}
+	for _, test := range tests {
+		sum, err := Dir(tree(t, test.files))
+		if err != nil {
+			t.Fatalf("%s: Dir: %v", test.name, err)
+		}
+		if (sum == baseSum) != test.same {
+			t.Errorf("%s: Dir = %s, base %s, want same %v", test.name, sum, baseSum, test.same)
+		}
+	}
+}
+
+func TestFilesMatchesDir(t *testing.T) {
+	dir := tree(t, map[string]string{"b.acid": "b", "a/c.acid": "c"})
+	dirSum, _ := Dir(dir)
+	filesSum, err := Files(dir, []string{"b.acid", "a/c.acid"})
+	if err != nil || filesSum != dirSum {
+		t.Errorf("Files = %s, %v; want Dir's %s whatever the order", filesSum, err, dirSum)
+	}
+	if _, err := Files(dir, []string{"missing.acid"}); err == nil {
+		t.Errorf("Files succeeded for a missing file")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"sha256:abc", "sha256:abc", true},
+		{"sha256:abc", "abc", true},
+		{"sha256:ABC", "sha256:abc", true},
+		{"sha256:abc", "sha256:abd", false},
+	}
+	for _, test := range tests {
+		if got := Equal(test.a, test.b); got != test.equal {
+			t.Errorf("Equal(%q, %q) = %v, want %v", test.a, test.b, got, test.equal)
+		}
+	}
+}
@@ -1,49 +1,112 @@
 package cmds
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
-func RunCommand(command string) error {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty command")
-	}
+// Run a command in workingDir, discarding its output.
+//
+// Arguments are passed to the command as given, so paths containing spaces
+// need no quoting. An empty workingDir runs the command in the current directory.
+func RunCommand(workingDir string, name string, args ...string) error {
+	_, err := RunCommandOutput(workingDir, name, args...)
+	return err
+}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	return cmd.Run()
+// Run a command in workingDir and get back its trimmed standard output.
+//
+// On failure the error includes the reason the command wrote to standard error.
+func RunCommandOutput(workingDir string, name string, args ...string) (string, error) {
+	return RunCommandEnv(workingDir, nil, 0, name, args...)
 }
 
-func RunCommandQuiet(command string) error {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty command")
-	}
+// Run a command like RunCommandOutput, adding env to the environment it
+// inherits and killing it once timeout has passed, unless timeout is zero.
+// The command never reads from the terminal's standard input.
+func RunCommandEnv(workingDir string, env []string, timeout time.Duration, name string, args ...string) (string, error) {
+	return run(workingDir, env, timeout, false, name, args...)
+}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	return cmd.Run()
+// Run a command like RunCommandEnv, getting back its standard output and
+// standard error together, for commands that report on standard error even
+// when they succeed.
+func RunCommandCombined(workingDir string, env []string, timeout time.Duration, name string, args ...string) (string, error) {
+	return run(workingDir, env, timeout, true, name, args...)
 }
 
-func RunCommandOutput(command string, workingDir string) (string, error) {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
+func run(workingDir string, env []string, timeout time.Duration, combined bool, name string, args ...string) (string, error) {
+	if name == "" {
 		return "", fmt.Errorf("empty command")
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	if workingDir != "" {
-		cmd.Dir = workingDir
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = workingDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
 	}
+	// Children such as git-remote-https may hold the output pipes open after
+	// the command itself is killed.
+	cmd.WaitDelay = time.Second
 
-	output, err := cmd.Output()
+	var (
+		output []byte
+		err    error
+	)
+	if combined {
+		output, err = cmd.CombinedOutput()
+	} else {
+		output, err = cmd.Output()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out after %s", name, timeout)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr := string(exitErr.Stderr)
+		if combined {
+			stderr = string(output)
+		}
+		if reason := failureReason(stderr); reason != "" {
+			err = fmt.Errorf("%v: %s", err, reason)
+		}
+	}
 	return strings.TrimSpace(string(output)), err
 }
 
+// Pick the line explaining a failure out of a command's standard error: the
+// first "fatal:" or "error:" line as git writes them, or else the last line.
+// A line just before it, such as ssh's "Permission denied (publickey).", is
+// kept as well.
+func failureReason(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "fatal:") && !strings.HasPrefix(line, "error:") {
+			continue
+		}
+		if i > 0 {
+			previous := strings.TrimSpace(lines[i-1])
+			if previous != "" && !strings.HasPrefix(previous, "hint:") && !strings.HasPrefix(previous, "warning:") {
+				return previous + " " + line
+			}
+		}
+		return line
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
 func FileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/acidlang/ace/advisory"
+	"github.com/acidlang/ace/config"
+	"github.com/acidlang/ace/git"
+	"github.com/acidlang/ace/license"
+	"github.com/acidlang/ace/modules"
+	"github.com/acidlang/ace/proxy"
+	"github.com/acidlang/ace/sbom"
+	"github.com/acidlang/ace/source"
+)
+
+func listModules(project *modules.Project) error {
+	installed, err := project.List()
+	if err != nil {
+		return err
+	}
+
+	if len(installed) == 0 {
+		fmt.Println("No modules installed.")
+		return nil
+	}
+
+	for _, module := range installed {
+		versionInfo := ""
+		if module.RequestedVersion != "" {
+			versionInfo = fmt.Sprintf(" (v%s)", module.RequestedVersion)
+		} else if len(module.CommitHash) >= 7 {
+			versionInfo = fmt.Sprintf(" (%s)", module.CommitHash[:7])
+		} else if module.Checksum != "" {
+			versionInfo = fmt.Sprintf(" (%s)", module.Checksum)
+		}
+
+		replaceInfo := ""
+		if module.Replace != "" {
+			replaceInfo = fmt.Sprintf(" => %s", module.Replace)
+		}
+
+		fmt.Printf("- %s%s @ %s%s (installed %s)\n", module.Name, versionInfo, module.Repo, replaceInfo, module.Timestamp)
+	}
+	return nil
+}
+
+func showModuleInfo(project *modules.Project, moduleName string) error {
+	module, err := project.Info(moduleName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Module: %s\n", module.Name)
+	fmt.Printf("Repository: %s\n", module.Repo)
+	if module.Replace != "" {
+		fmt.Printf("Replaced By: %s\n", project.DescribeReplacement(module.Replace))
+	}
+	if replacement, ok := project.Replacements()[moduleName]; ok && replacement != module.Replace {
+		fmt.Printf("Warning: module.acidcfg replaces %s with %s, run 'ace restore' to apply it\n", moduleName, replacement)
+	} else if !ok && module.Replace != "" {
+		fmt.Printf("Warning: module.acidcfg no longer replaces %s, run 'ace restore' to apply it\n", moduleName)
+	}
+	fmt.Printf("Installed At: %s\n", module.Timestamp)
+
+	if module.RequestedVersion != "" {
+		fmt.Printf("Requested Version: %s\n", module.RequestedVersion)
+	}
+	if module.CommitHash != "" {
+		fmt.Printf("Commit Hash: %s\n", module.CommitHash)
+	}
+	if module.Checksum != "" {
+		fmt.Printf("Checksum: %s\n", module.Checksum)
+	}
+	if module.Signer != "" {
+		fmt.Printf("Signed By: %s\n", module.Signer)
+	}
+	if module.Branch != "" {
+		fmt.Printf("Branch: %s\n", module.Branch)
+	}
+	if len(module.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(module.Tags, ", "))
+	}
+
+	if module.Config == nil {
+		fmt.Printf("Warning: no valid module.acidcfg found in %s\n", module.Dir)
+		return nil
+	}
+	if module.Config.Author != "" {
+		fmt.Printf("Author: %s\n", module.Config.Author)
+	}
+	if module.Config.Version != "" {
+		fmt.Printf("Module Version: %s\n", module.Config.Version)
+	}
+	if module.Config.Description != "" {
+		fmt.Printf("Description: %s\n", module.Config.Description)
+	}
+	if module.Config.License != "" {
+		fmt.Printf("License: %s\n", module.Config.License)
+	}
+	if module.Config.Homepage != "" {
+		fmt.Printf("Homepage: %s\n", module.Config.Homepage)
+	}
+	if module.Config.Repository != "" && !source.Same(module.Config.Repository, module.Repo) {
+		fmt.Printf("Upstream Repository: %s\n", module.Config.Repository)
+	}
+	if len(module.Config.Keywords) > 0 {
+		fmt.Printf("Keywords: %s\n", strings.Join(module.Config.Keywords, ", "))
+	}
+	if module.Config.Entry != "" {
+		fmt.Printf("Entry: %s\n", module.Config.Entry)
+	}
+	if module.Config.Acid != "" {
+		fmt.Printf("Requires Acid: %s\n", module.Config.Acid)
+	}
+	if module.Config.Ace != "" {
+		fmt.Printf("Requires ace: %s\n", module.Config.Ace)
+	}
+	return nil
+}
+
+func printDependencyGraph(project *modules.Project) error {
+	graph, err := project.Graph()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("* %s\n", graph.Name)
+	for i, module := range graph.Dependencies {
+		isLast := i == len(graph.Dependencies)-1
+		prefix := "└──"
+		infoPrefix := "     "
+		if !isLast {
+			prefix = "├──"
+			infoPrefix = "│   "
+		}
+
+		version := module.RequestedVersion
+		if version == "" && len(module.CommitHash) >= 7 {
+			version = module.CommitHash[:7]
+		}
+		if version == "" && module.Checksum != "" {
+			version = module.Checksum
+		}
+		if version == "" {
+			version = "latest"
+		}
+		fmt.Printf("%s - %s (%s)\n", prefix, module.Name, version)
+
+		if module.Replace != "" {
+			fmt.Printf("%s => %s\n", infoPrefix, project.DescribeReplacement(module.Replace))
+		}
+		if module.Branch != "" {
+			fmt.Printf("%s branch: %s\n", infoPrefix, module.Branch)
+		}
+		if len(module.Tags) > 0 {
+			fmt.Printf("%s tags: %s\n", infoPrefix, module.Tags[0])
+		}
+	}
+	return nil
+}
+
+// Check the project for inconsistencies, applying safe repairs when fix is
+// set or when the user accepts them. Returns ErrCheckFailed if problems remain.
+func runDoctor(project *modules.Project, fix bool) error {
+	issues := project.Diagnose()
+	if len(issues) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	fixable := 0
+	for _, issue := range issues {
+		marker := "-"
+		if issue.Fix != nil {
+			fixable++
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, issue.Description)
+	}
+
+	fmt.Printf("\nFound %d problem(s), %d with a safe repair (marked *).\n", len(issues), fixable)
+	if fixable == 0 {
+		return fmt.Errorf("%w: %d problem(s) need attention", modules.ErrCheckFailed, len(issues))
+	}
+
+	if project.Frozen() {
+		// Every repair changes acid.lock or pkg/, which frozen mode forbids.
+		fmt.Println("Not repairing, acid.lock is frozen.")
+		return fmt.Errorf("%w: %d problem(s) need attention", modules.ErrCheckFailed, len(issues))
+	}
+	if !fix && !confirm("Apply the safe repairs?") {
+		fmt.Println("Run 'ace doctor --fix' to apply them.")
+		return fmt.Errorf("%w: %d problem(s) need attention", modules.ErrCheckFailed, len(issues))
+	}
+
+	remaining := len(issues) - fixable
+	for _, issue := range issues {
+		if issue.Fix == nil {
+			continue
+		}
+		if err := issue.Fix(); err != nil {
+			fmt.Printf("Could not repair '%s': %v\n", issue.Description, err)
+			remaining++
+		}
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("%w: %d problem(s) still need attention", modules.ErrCheckFailed, remaining)
+	}
+	fmt.Println("All problems repaired.")
+	return nil
+}
+
+// Remove unused modules and install missing ones. With check set nothing is
+// changed, and ErrCheckFailed is returned if the project is not tidy.
+func runTidy(project *modules.Project, check bool) error {
+	report, err := project.CheckTidy()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range report.Unused {
+		fmt.Printf("Unused module: %s\n", name)
+	}
+	for _, name := range report.Missing {
+		fmt.Printf("Missing module: %s\n", name)
+	}
+	for _, name := range report.Unresolved {
+		fmt.Printf("Note: import '%s' is not installed, local or in a registry\n", name)
+	}
+	for _, name := range report.UnusedReplacements {
+		fmt.Printf("Note: module.acidcfg replaces %s, which is not used\n", name)
+	}
+
+	if report.Tidy() {
+		fmt.Println("Project is tidy.")
+		return nil
+	}
+
+	if check {
+		fmt.Println("Run 'ace tidy' to fix it.")
+		return fmt.Errorf("%w: project is not tidy", modules.ErrCheckFailed)
+	}
+
+	_, err = project.Tidy()
+	return err
+}
+
+// Show or change settings: config list, config get <name> and
+// config set <name> <value> [--project].
+func runConfig(root string, args []string) error {
+	project := false
+	var params []string
+	for _, arg := range args {
+		if arg == "--project" {
+			project = true
+		} else {
+			params = append(params, arg)
+		}
+	}
+
+	usage := fmt.Errorf("%w: expected 'config list', 'config get <name>' or 'config set <name> <value> [--project]'", errUsage)
+	if len(params) == 0 {
+		return usage
+	}
+
+	switch params[0] {
+	case "list":
+		settings, warnings, err := config.LoadWithWarnings(root)
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, key := range config.Keys {
+			names := []string{key.Name}
+			if strings.HasSuffix(key.Name, ".") {
+				names = names[:0]
+				for rest := range settings.Family(key.Name) {
+					names = append(names, key.Name+rest)
+				}
+				sort.Strings(names)
+			}
+			for _, name := range names {
+				value := settings[name]
+				fmt.Fprintf(w, "%s = %s\t(%s)\n", name, shown(key, value.Value), value.Source)
+			}
+		}
+		return w.Flush()
+
+	case "get":
+		if len(params) != 2 {
+			return usage
+		}
+		key, known := config.Lookup(params[1])
+		if !known {
+			return fmt.Errorf("%w: unknown setting '%s', see 'ace config list'", errUsage, params[1])
+		}
+		settings, err := config.Load(root)
+		if err != nil {
+			return err
+		}
+		fmt.Println(shown(key, settings.Get(params[1])))
+		return nil
+
+	case "set":
+		if len(params) != 3 {
+			return usage
+		}
+		path := config.UserPath()
+		if project {
+			if err := config.CheckProject(params[1]); err != nil {
+				return err
+			}
+			path = config.ProjectPath(root)
+		}
+		if path == "" {
+			return fmt.Errorf("no user configuration directory, set ACE_CONFIG or use --project")
+		}
+		if err := config.Set(path, params[1], params[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Updated %s.\n", path)
+		return nil
+	}
+	return usage
+}
+
+// Hide the value of a secret setting unless it only names an environment variable.
+func shown(key config.Key, value string) string {
+	if key.Secret && value != "" && !strings.HasPrefix(value, "env:") {
+		return "(hidden)"
+	}
+	return value
+}
+
+// Apply the loaded settings to the project options and the git backend
+// they select.
+func applyConfig(settings config.Config, options *modules.Options) error {
+	jobs, err := settings.Int("jobs")
+	if err != nil {
+		return err
+	}
+	registryTimeout, err := settings.Duration("timeout")
+	if err != nil {
+		return err
+	}
+	gitTimeout, err := settings.Duration("git_timeout")
+	if err != nil {
+		return err
+	}
+
+	options.Jobs = jobs
+	options.PublishRegistry = settings.Get("publish_registry")
+	options.Proxies = settings.List("proxy")
+	for _, base := range options.Proxies {
+		if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") && !strings.HasPrefix(base, "file://") {
+			return fmt.Errorf("%w: proxy %s is not an http(s):// or file:// URL", modules.ErrInvalidConfig, base)
+		}
+	}
+	options.CacheDir = cacheDir(settings)
+	options.LicensePolicy = license.Policy{Allow: settings.List("license_allow"), Deny: settings.List("license_deny")}
+	options.Signers = make(map[string][]string)
+	for moduleName := range settings.Family("signers.") {
+		options.Signers[moduleName] = settings.List("signers." + moduleName)
+	}
+	options.Registries = settings.List("registries")
+	options.Timeout = registryTimeout
+	options.Rewrites = settings.Family("rewrite.")
+
+	cli := git.CLI{
+		Executable: settings.Get("git"),
+		SSHKey:     settings.Get("ssh_key"),
+		Timeout:    gitTimeout,
+		Rewrites:   options.Rewrites,
+		Tokens:     make(map[string]string),
+	}
+	if rest, ok := strings.CutPrefix(cli.SSHKey, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			cli.SSHKey = filepath.Join(home, rest)
+		}
+	}
+	for host, token := range settings.Family("token.") {
+		// Tokens may live in the environment, e.g. token.github.com = env:GITHUB_TOKEN.
+		if name, ok := strings.CutPrefix(token, "env:"); ok {
+			token = os.Getenv(name)
+		}
+		if token != "" {
+			cli.Tokens[host] = token
+		}
+	}
+	options.Git = cli
+	return nil
+}
+
+// List every module's license, marking those the license policy does not
+// allow. Returns ErrCheckFailed if there are any.
+func runLicenses(project *modules.Project) error {
+	licenses, err := project.Licenses()
+	if err != nil {
+		return err
+	}
+
+	policy := project.LicensePolicy()
+	denied := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, module := range licenses {
+		id, source := module.License, module.Source
+		if id == "" {
+			id = "unknown"
+		}
+		if source == "" {
+			source = "no license file"
+		}
+
+		status := ""
+		if policy.Enabled() && !policy.Allows(module.License) {
+			status = "\tnot allowed"
+			denied++
+		}
+		fmt.Fprintf(w, "%s\t%s\t(%s)%s\n", module.Module, id, source, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if denied > 0 {
+		return fmt.Errorf("%w: %d module(s) have licenses the policy does not allow", modules.ErrCheckFailed, denied)
+	}
+	return nil
+}
+
+// Write a software bill of materials in the given format to outputFile, or
+// to stdout when it is empty.
+func runSBOM(project *modules.Project, format, outputFile string) error {
+	if format != sbom.CycloneDX && format != sbom.SPDX {
+		return fmt.Errorf("%w: unknown SBOM format '%s', expected --format=%s or --format=%s", errUsage, format, sbom.CycloneDX, sbom.SPDX)
+	}
+
+	doc, err := project.SBOM()
+	if err != nil {
+		return err
+	}
+	doc.Tool, doc.ToolVersion = "ace", version
+
+	if outputFile == "" {
+		return sbom.Write(os.Stdout, format, doc)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := sbom.Write(file, format, doc); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s SBOM to %s\n", format, outputFile)
+	return nil
+}
+
+// Run ace audit against the advisory database at location, downloading it
+// within timeout, and upgrade affected modules with fix.
+func runAudit(project *modules.Project, location string, timeout time.Duration, fix bool) error {
+	if location == "" {
+		return fmt.Errorf("%w: no advisory database, pass --db=<path|url> or set advisory_db", errUsage)
+	}
+	db, err := advisory.Load(location, timeout)
+	if err != nil {
+		return err
+	}
+
+	findings, err := project.Audit(db)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		fmt.Printf("No known vulnerabilities in %s (%d advisories checked).\n", project.LockPath(), len(db.Advisories))
+		return nil
+	}
+
+	printFindings(findings)
+	if !fix {
+		fmt.Println("Run 'ace audit --fix' to upgrade to fixed versions.")
+		return fmt.Errorf("%w: %d advisory finding(s)", modules.ErrCheckFailed, len(findings))
+	}
+
+	_, fixErr := project.FixAdvisories(db, findings)
+	remaining, err := project.Audit(db)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		fmt.Println("\nStill affected:")
+		printFindings(remaining)
+		if fixErr != nil {
+			return fmt.Errorf("%w: %d advisory finding(s) remain, %v", modules.ErrCheckFailed, len(remaining), fixErr)
+		}
+		return fmt.Errorf("%w: %d advisory finding(s) remain", modules.ErrCheckFailed, len(remaining))
+	}
+	fmt.Println("Every affected module was upgraded to a fixed version.")
+	return fixErr
+}
+
+// Print audit findings grouped by module.
+func printFindings(findings []modules.Finding) {
+	modulesAffected := 0
+	for i, finding := range findings {
+		if i == 0 || findings[i-1].Module != finding.Module {
+			modulesAffected++
+			version := finding.Version
+			if commit := finding.Entry.CommitHash; version == "" && len(commit) >= 7 {
+				version = "commit " + commit[:7]
+			}
+			fmt.Printf("%s %s\n", finding.Module, version)
+		}
+
+		adv := finding.Advisory
+		severity := adv.Severity
+		if severity == "" {
+			severity = "unknown"
+		}
+		fmt.Printf("  %s [%s] %s\n", adv.ID, severity, adv.Summary)
+		if fixed := adv.FixedVersions(); len(fixed) > 0 {
+			fmt.Printf("    fixed in: %s\n", strings.Join(fixed, ", "))
+		} else {
+			fmt.Println("    no fixed version yet")
+		}
+		if adv.URL != "" {
+			fmt.Printf("    %s\n", adv.URL)
+		}
+	}
+	fmt.Printf("\nFound %d advisory finding(s) in %d module(s).\n", len(findings), modulesAffected)
+}
+
+// Get the module cache directory from the cache_dir setting, "" when the
+// cache is turned off.
+func cacheDir(settings config.Config) string {
+	dir := settings.Get("cache_dir")
+	switch {
+	case dir == "off":
+		return ""
+	case dir != "":
+		return dir
+	}
+
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(userCache, "ace", "modules")
+}
+
+// Run "ace proxy serve [dir] [--addr=<addr>]", serving a module cache
+// directory, the configured cache by default, to other machines.
+func runProxy(args []string, options modules.Options) error {
+	addr := ":8080"
+	var params []string
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--addr="); ok {
+			addr = value
+		} else {
+			params = append(params, arg)
+		}
+	}
+
+	if len(params) == 0 || params[0] != "serve" || len(params) > 2 {
+		return fmt.Errorf("%w: expected 'proxy serve [dir] [--addr=<host:port>]'", errUsage)
+	}
+
+	dir := options.CacheDir
+	if len(params) == 2 {
+		dir = params[1]
+	}
+	if dir == "" {
+		return fmt.Errorf("%w: no directory given and cache_dir is off", errUsage)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", modules.ErrNotFound, dir)
+	}
+
+	fmt.Printf("Serving %s on %s\n", dir, addr)
+	return proxy.Serve(dir, addr, func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+	})
+}
+
+// Ask a yes/no question on stdin, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
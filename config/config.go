@@ -0,0 +1,336 @@
+// Package config reads ace's settings from the user configuration file, a
+// project's .ace/config and the environment. Both files hold "name = value"
+// lines:
+//
+//	# ~/.config/ace/config
+//	registries = https://registry.example.org, /srv/acid
+//	jobs = 4
+//
+// Settings that run programs or direct credentials, such as git and
+// token.<host>, are ignored in a project's file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kinds of value a setting holds.
+const (
+	String   = "string"
+	List     = "list"
+	Int      = "int"
+	Duration = "duration"
+)
+
+// A setting ace reads from its configuration files.
+//
+// A Name ending in "." is a family of settings, e.g. rewrite.<prefix>.
+type Key struct {
+	Name string
+	Kind string
+	// Environment variable overriding the files, if any.
+	Env         string
+	Default     string
+	Description string
+	// Hidden from ace config list and get, and kept in a private file.
+	Secret bool
+	// Only read from the user file and the environment, never from a
+	// project's, as it runs programs or decides where credentials go.
+	UserOnly bool
+}
+
+// Every setting ace knows about.
+var Keys = []Key{
+	{Name: "registries", Kind: List, Env: "ACE_REGISTRY", Description: "registries resolving short names, highest priority first"},
+	{Name: "publish_registry", Kind: String, Env: "ACE_PUBLISH_REGISTRY", Description: "registry ace publish announces releases to"},
+	{Name: "jobs", Kind: Int, Env: "ACE_JOBS", Default: "1", Description: "modules restored in parallel"},
+	{Name: "git", Kind: String, Env: "ACE_GIT", Default: "git", UserOnly: true, Description: "git executable to run"},
+	{Name: "timeout", Kind: Duration, Env: "ACE_TIMEOUT", Default: "30s", Description: "timeout for registry requests and archive downloads"},
+	{Name: "git_timeout", Kind: Duration, Env: "ACE_GIT_TIMEOUT", Default: "0", Description: "timeout for each git command, 0 for none"},
+	{Name: "rewrite.", Kind: String, UserOnly: true, Description: "rewrite.<prefix> = <replacement> fetches URLs starting with <prefix> from <replacement> instead"},
+	{Name: "ssh_key", Kind: String, Env: "ACE_SSH_KEY", UserOnly: true, Description: "private key for SSH remotes, instead of the agent and default keys"},
+	{Name: "proxy", Kind: List, Env: "ACE_PROXY", Description: "module proxies tried for pinned versions before git, first match wins"},
+	{Name: "cache_dir", Kind: String, Env: "ACE_CACHE_DIR", Description: "cache of pinned module versions, \"off\" for none; defaults to the user cache directory"},
+	{Name: "advisory_db", Kind: String, Env: "ACE_ADVISORY_DB", Description: "advisory database ace audit checks against, a JSON file or URL"},
+	{Name: "license_allow", Kind: List, Env: "ACE_LICENSE_ALLOW", Description: "SPDX licenses modules may be added under, any when empty"},
+	{Name: "license_deny", Kind: List, Env: "ACE_LICENSE_DENY", Description: "SPDX licenses modules may never be added under"},
+	{Name: "signers.", Kind: List, Description: "signers.<module> = <key>, ... requires <module>'s tag or commit to be signed by one of the keys"},
+	{Name: "token.", Kind: String, Secret: true, UserOnly: true, Description: "token.<host> = <token> or env:<VAR> authenticates HTTPS requests to <host>"},
+}
+
+// Find a setting by name.
+func Lookup(name string) (Key, bool) {
+	for _, key := range Keys {
+		if key.Name == name || key.family() && strings.HasPrefix(name, key.Name) && len(name) > len(key.Name) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+func (key Key) family() bool {
+	return strings.HasSuffix(key.Name, ".")
+}
+
+// A setting's effective value and where it came from: "default", a
+// configuration file path or an environment variable.
+type Value struct {
+	Value  string
+	Source string
+}
+
+// The effective configuration, merged from the defaults, the user file, the
+// project file and the environment, each overriding the one before.
+type Config map[string]Value
+
+// Get the path of the user configuration file, ~/.config/ace/config on Linux.
+//
+// ACE_CONFIG overrides it.
+func UserPath() string {
+	if path := os.Getenv("ACE_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ace", "config")
+}
+
+// Get the path of a project's configuration file, overriding the user's.
+func ProjectPath(root string) string {
+	return filepath.Join(root, ".ace", "config")
+}
+
+// Load the effective configuration for the project at root.
+func Load(root string) (Config, error) {
+	config, _, err := LoadWithWarnings(root)
+	return config, err
+}
+
+// Load the effective configuration for the project at root, along with
+// warnings about settings in the project's file that were ignored.
+func LoadWithWarnings(root string) (Config, []error, error) {
+	config := make(Config)
+	var warnings []error
+	for _, key := range Keys {
+		if !key.family() {
+			config[key.Name] = Value{Value: key.Default, Source: "default"}
+		}
+	}
+
+	projectPath := ProjectPath(root)
+	for _, path := range []string{UserPath(), projectPath} {
+		if path == "" {
+			continue
+		}
+		values, err := Read(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		for name, value := range values {
+			if path == projectPath {
+				if err := CheckProject(name); err != nil {
+					warnings = append(warnings, fmt.Errorf("%s: %v, ignoring it", path, err))
+					continue
+				}
+			}
+			if value != "" {
+				config[name] = Value{Value: value, Source: path}
+			}
+		}
+	}
+
+	for _, key := range Keys {
+		if value := os.Getenv(key.Env); value != "" && key.Env != "" {
+			config[key.Name] = Value{Value: value, Source: "$" + key.Env}
+		}
+	}
+
+	for name, value := range config {
+		if value.Source == "default" {
+			continue
+		}
+		if err := Check(name, value.Value); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", value.Source, err)
+		}
+	}
+	return config, warnings, nil
+}
+
+// Get a setting's effective value.
+func (config Config) Get(name string) string {
+	return config[name].Value
+}
+
+// Get a setting as a comma separated list.
+func (config Config) List(name string) []string {
+	var values []string
+	for value := range strings.SplitSeq(config.Get(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Get the settings in a family such as "rewrite.", keyed by the rest of their
+// names.
+func (config Config) Family(prefix string) map[string]string {
+	values := make(map[string]string)
+	for name, value := range config {
+		if rest, found := strings.CutPrefix(name, prefix); found {
+			values[rest] = value.Value
+		}
+	}
+	return values
+}
+
+// Get a setting as a whole number.
+func (config Config) Int(name string) (int, error) {
+	value := config[name]
+	n, err := strconv.Atoi(value.Value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number, got '%s'", name, value.Value)
+	}
+	return n, nil
+}
+
+// Get a setting as a duration such as "30s" or "2m". A plain number is read
+// as seconds.
+func (config Config) Duration(name string) (time.Duration, error) {
+	value := config[name]
+	if seconds, err := strconv.Atoi(value.Value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration such as 30s, got '%s'", name, value.Value)
+	}
+	return d, nil
+}
+
+// Check that value is valid for the setting name.
+func Check(name, value string) error {
+	key, known := Lookup(name)
+	if !known {
+		return fmt.Errorf("unknown setting '%s'", name)
+	}
+
+	check := Config{name: Value{Value: value}}
+	var err error
+	switch key.Kind {
+	case Int:
+		var n int
+		if n, err = check.Int(name); err == nil && n < 1 {
+			err = fmt.Errorf("%s must be at least 1, got %d", name, n)
+		}
+	case Duration:
+		var d time.Duration
+		if d, err = check.Duration(name); err == nil && d < 0 {
+			err = fmt.Errorf("%s must not be negative, got %s", name, value)
+		}
+	}
+	return err
+}
+
+// Check that a setting may be set in a project's configuration file, which
+// comes with the checkout and so cannot be trusted to pick the programs ace
+// runs or where credentials are sent.
+func CheckProject(name string) error {
+	if key, known := Lookup(name); known && key.UserOnly {
+		return fmt.Errorf("%s can only be set in the user configuration or the environment, not in a project", name)
+	}
+	return nil
+}
+
+// Read a configuration file of "name = value" lines. Blank lines and lines
+// starting with '#' are ignored.
+func Read(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected 'name = value'", path, i+1)
+		}
+		if _, known := Lookup(name); !known {
+			return nil, fmt.Errorf("%s:%d: unknown setting '%s'", path, i+1, name)
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// Set a value in a configuration file, creating the file if needed. An empty
+// value removes the setting. Comments and other settings are kept as they are.
+func Set(path, name, value string) error {
+	key, known := Lookup(name)
+	if !known {
+		return fmt.Errorf("unknown setting '%s', see 'ace config list'", name)
+	}
+	if strings.ContainsAny(value, "\n\r") {
+		return fmt.Errorf("value for %s must be a single line", name)
+	}
+	if value != "" {
+		if err := Check(name, value); err != nil {
+			return err
+		}
+	}
+
+	var lines []string
+	if content, err := os.ReadFile(path); err == nil {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	replaced := false
+	kept := lines[:0]
+	for _, line := range lines {
+		lineName, _, found := strings.Cut(strings.TrimSpace(line), "=")
+		if found && strings.TrimSpace(lineName) == name && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			if value != "" && !replaced {
+				kept = append(kept, fmt.Sprintf("%s = %s", name, value))
+			}
+			replaced = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !replaced && value != "" {
+		kept = append(kept, fmt.Sprintf("%s = %s", name, value))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if key.Secret {
+		// WriteFile keeps the mode of an existing file, so tighten it first.
+		mode = 0600
+		if err := os.Chmod(path, mode); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")+"\n"), mode)
+}
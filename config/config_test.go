@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write a user file and a project's .ace/config, returning the project root.
+func setup(t *testing.T, user, project string) string {
+	t.Helper()
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user", "config")
+	t.Setenv("ACE_CONFIG", userPath)
+	for _, key := range Keys {
+		if key.Env != "" {
+			t.Setenv(key.Env, "")
+		}
+	}
+
+	root := filepath.Join(dir, "project")
+	for path, content := range map[string]string{userPath: user, ProjectPath(root): project} {
+		if content == "" {
+			continue
+		}
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		project string
+		env     string
+		value   string
+		source  string
+	}{
+		{"default", "", "", "", "1", "default"},
+		{"user", "jobs = 2", "", "", "2", "user"},
+		{"project over user", "jobs = 2", "jobs = 3", "", "3", "project"},
+		{"environment over files", "jobs = 2", "jobs = 3", "4", "4", "$ACE_JOBS"},
+		{"empty value unsets", "jobs = 2", "jobs =", "", "2", "user"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := setup(t, test.user, test.project)
+			t.Setenv("ACE_JOBS", test.env)
+
+			config, err := Load(root)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			source := map[string]string{UserPath(): "user", ProjectPath(root): "project"}[config["jobs"].Source]
+			if source == "" {
+				source = config["jobs"].Source
+			}
+			if got := config.Get("jobs"); got != test.value || source != test.source {
+				t.Errorf("jobs = %q from %s, want %q from %s", got, source, test.value, test.source)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoresUserOnlySettingsInProject(t *testing.T) {
+	root := setup(t, "git = /usr/bin/git\n", "git = ./evil\ntoken.example.org = secret\nrewrite.https://a/ = https://b/\njobs = 3\n")
+
+	config, warnings, err := LoadWithWarnings(root)
+	if err != nil {
+		t.Fatalf("LoadWithWarnings: %v", err)
+	}
+	if got := config.Get("git"); got != "/usr/bin/git" {
+		t.Errorf("git = %q, want the user's", got)
+	}
+	if _, set := config["token.example.org"]; set {
+		t.Errorf("token.example.org was read from the project")
+	}
+	if len(config.Family("rewrite.")) != 0 {
+		t.Errorf("rewrite rules were read from the project")
+	}
+	if got := config.Get("jobs"); got != "3" {
+		t.Errorf("jobs = %q, want the project's other settings kept", got)
+	}
+	if len(warnings) != 3 {
+		t.Errorf("warnings = %v, want one for each ignored setting", warnings)
+	}
+	for _, warning := range warnings {
+		if !strings.Contains(warning.Error(), ProjectPath(root)) {
+			t.Errorf("warning %q does not name the project file", warning)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		project string
+		env     string
+	}{
+		{"unknown setting", "colour = blue", "", ""},
+		{"malformed line", "jobs", "", ""},
+		{"jobs below one", "", "jobs = 0", ""},
+		{"bad duration", "timeout = soon", "", ""},
+		{"negative duration in environment", "", "", "-1s"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := setup(t, test.user, test.project)
+			t.Setenv("ACE_TIMEOUT", test.env)
+			if _, err := Load(root); err == nil {
+				t.Errorf("Load succeeded")
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"2m", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		got, err := Config{"timeout": {Value: test.value}}.Duration("timeout")
+		if got != test.want || (err == nil) != test.ok {
+			t.Errorf("Duration(%q) = %v, %v; want %v", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(path, []byte("# settings\njobs = 2\nregistries = /srv/acid\n"), 0644)
+
+	if err := Set(path, "jobs", "4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(path, "registries", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(path, "token.example.org", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+	if want := "# settings\njobs = 4\ntoken.example.org = secret\n"; string(content) != want {
+		t.Errorf("config = %q, want %q", content, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("a file holding a token is not private: %v", info.Mode())
+	}
+
+	for _, value := range []string{"0", "a\nb"} {
+		if err := Set(path, "jobs", value); err == nil {
+			t.Errorf("Set(jobs, %q) succeeded", value)
+		}
+	}
+	if err := Set(path, "colour", "blue"); err == nil {
+		t.Errorf("Set of an unknown setting succeeded")
+	}
+}
+
+func TestCheckProject(t *testing.T) {
+	for _, name := range []string{"git", "ssh_key", "rewrite.https://a/", "token.example.org"} {
+		if CheckProject(name) == nil {
+			t.Errorf("CheckProject(%s) allowed a user-only setting", name)
+		}
+	}
+	for _, name := range []string{"registries", "jobs", "signers.json"} {
+		if err := CheckProject(name); err != nil {
+			t.Errorf("CheckProject(%s) = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/acidlang/ace/cmds"
+	"github.com/acidlang/ace/source"
+)
+
+// Reported when a remote asked for credentials ace could not supply.
+var ErrAuthRequired = errors.New("authentication required")
+
+// Run git with the non-interactive environment.
+func (c CLI) run(workingDir string, args ...string) error {
+	_, err := c.output(workingDir, args...)
+	return err
+}
+
+// Run git with the non-interactive environment and get back its output.
+//
+// Errors never contain the configured tokens or credentials embedded in URLs.
+func (c CLI) output(workingDir string, args ...string) (string, error) {
+	output, err := cmds.RunCommandEnv(workingDir, c.environment(), c.Timeout, c.Path(), args...)
+	if err != nil {
+		err = c.authError(err)
+	}
+	return output, err
+}
+
+// Build the environment that keeps git from ever prompting: credential
+// helpers still run, but a missing password fails instead of waiting on the
+// terminal, and tokens are sent as an Authorization header scoped to their
+// host rather than appearing in a URL or on the command line.
+func (c CLI) environment() []string {
+	env := []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"}
+
+	if c.SSHKey != "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes -o IdentitiesOnly=yes -i "+quote(c.SSHKey))
+	} else if os.Getenv("GIT_SSH_COMMAND") == "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	if len(c.Tokens) == 0 {
+		return env
+	}
+
+	// Keep any configuration the caller already passes this way.
+	offset, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	hosts := make([]string, 0, len(c.Tokens))
+	for host := range c.Tokens {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for i, host := range hosts {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=http.https://%s/.extraHeader", offset+i, host),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=Authorization: Basic %s", offset+i, basicAuth(c.Tokens[host])))
+	}
+	return append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(offset+len(hosts)))
+}
+
+func basicAuth(token string) string {
+	if !strings.Contains(token, ":") {
+		token = "x-access-token:" + token
+	}
+	return base64.StdEncoding.EncodeToString([]byte(token))
+}
+
+// Quote a path for the shell git runs GIT_SSH_COMMAND with.
+func quote(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// Messages git and ssh print when a remote wants credentials.
+var authFailures = []string{
+	"terminal prompts disabled",
+	"could not read Username",
+	"could not read Password",
+	"Authentication failed",
+	"Permission denied (publickey",
+	"Host key verification failed",
+	"Invalid username or password",
+}
+
+// Redact an error from git, and explain how to authenticate when that is
+// why it failed.
+func (c CLI) authError(err error) error {
+	message := c.redact(err.Error())
+	for _, failure := range authFailures {
+		if strings.Contains(message, failure) {
+			return fmt.Errorf("%w: %s (configure a git credential helper, a token.<host> setting or ssh_key, and make sure the host key is known)", ErrAuthRequired, message)
+		}
+	}
+	return errors.New(message)
+}
+
+func (c CLI) redact(s string) string {
+	s = source.Redact(s)
+	for _, token := range c.Tokens {
+		if token != "" {
+			s = strings.ReplaceAll(s, token, "***")
+			s = strings.ReplaceAll(s, basicAuth(token), "***")
+		}
+	}
+	return s
+}
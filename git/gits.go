@@ -2,21 +2,50 @@ package git
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/acidlang/ace/cmds"
+	"github.com/acidlang/ace/source"
 )
 
-func GetGitCommitHash(repoPath string) string {
-	output, err := cmds.RunCommandOutput("git rev-parse HEAD", repoPath)
+// The git backend that runs the git executable, for use wherever ace needs
+// an interchangeable implementation of git. The zero value runs "git" with
+// no credentials, rewrite rules or timeout of its own.
+type CLI struct {
+	// The git executable to run, "git" when empty.
+	Executable string
+	// Access tokens for HTTPS hosts, keyed by host name, from the
+	// token.<host> settings. A token of the form "user:token" sets the user
+	// name too.
+	Tokens map[string]string
+	// Private key used for SSH remotes, from the ssh_key setting. When empty,
+	// ssh uses the agent and its default keys.
+	SSHKey string
+	// How long each git command may run before it is killed, no limit when zero.
+	Timeout time.Duration
+	// Rewrite rules applied to the URLs cloned and fetched from.
+	Rewrites source.Rewrites
+}
+
+// Get the git executable the CLI runs.
+func (c CLI) Path() string {
+	if c.Executable == "" {
+		return "git"
+	}
+	return c.Executable
+}
+
+func (c CLI) CommitHash(repoPath string) string {
+	output, err := c.output(repoPath, "rev-parse", "HEAD")
 	if err != nil {
 		return ""
 	}
 	return output
 }
 
-func GetGitTags(repoPath string) []string {
-	output, err := cmds.RunCommandOutput("git tag --points-at HEAD", repoPath)
+func (c CLI) Tags(repoPath string) []string {
+	output, err := c.output(repoPath, "tag", "--points-at", "HEAD")
 	if err != nil || output == "" {
 		return []string{}
 	}
@@ -32,16 +61,16 @@ func GetGitTags(repoPath string) []string {
 	return tags
 }
 
-func GetGitCurrentBranch(repoPath string) string {
-	output, err := cmds.RunCommandOutput("git branch --show-current", repoPath)
+func (c CLI) CurrentBranch(repoPath string) string {
+	output, err := c.output(repoPath, "branch", "--show-current")
 	if err != nil {
 		return ""
 	}
 	return output
 }
 
-func GetLatestCommitHash(repoURL string) string {
-	output, err := cmds.RunCommandOutput(fmt.Sprintf("git ls-remote %s HEAD", repoURL), "")
+func (c CLI) LatestCommitHash(repoURL string) string {
+	output, err := c.output("", "ls-remote", "--", c.Rewrites.Apply(repoURL), "HEAD")
 	if err != nil || output == "" {
 		return ""
 	}
@@ -52,3 +81,78 @@ func GetLatestCommitHash(repoURL string) string {
 	}
 	return ""
 }
+
+// Check whether the working tree has no uncommitted or untracked changes.
+func (c CLI) IsClean(repoPath string) (bool, error) {
+	output, err := c.output(repoPath, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return output == "", nil
+}
+
+func (c CLI) TagExists(repoPath, tag string) bool {
+	_, err := c.output(repoPath, "rev-parse", "-q", "--verify", "refs/tags/"+tag)
+	return err == nil
+}
+
+func (c CLI) RemoteTagExists(repoPath, remote, tag string) bool {
+	output, err := c.output(repoPath, "ls-remote", "--tags", "--", remote, "refs/tags/"+tag)
+	return err == nil && output != ""
+}
+
+func (c CLI) RemoteURL(repoPath, remote string) string {
+	output, err := c.output(repoPath, "remote", "get-url", remote)
+	if err != nil {
+		return ""
+	}
+	return output
+}
+
+// Create an annotated tag at HEAD.
+func (c CLI) CreateTag(repoPath, tag string) error {
+	_, err := c.output(repoPath, "tag", "-a", tag, "-m", tag)
+	return err
+}
+
+func (c CLI) PushTag(repoPath, remote, tag string) error {
+	_, err := c.output(repoPath, "push", remote, "refs/tags/"+tag)
+	return err
+}
+
+func (c CLI) Checkout(repoPath, ref string) error {
+	if err := checkRef(ref); err != nil {
+		return err
+	}
+	return c.run(repoPath, "checkout", "-q", ref)
+}
+
+// Fetch a single ref or commit from a remote, e.g. into a shallow clone.
+func (c CLI) Fetch(repoPath, remote, ref string) error {
+	if err := checkRef(ref); err != nil {
+		return err
+	}
+	return c.run(repoPath, "fetch", "-q", "--depth", "1", "--", c.Rewrites.Apply(remote), ref)
+}
+
+// Clone a repository into dir, fetching only the latest commit when shallow is set.
+// The URL is passed through the rewrite rules first.
+//
+// A relative dir is created relative to its parent directory, never the
+// process working directory of some earlier operation.
+func (c CLI) Clone(repoURL, dir string, shallow bool) error {
+	args := []string{"clone", "-q"}
+	if shallow {
+		args = append(args, "--depth", "1")
+	}
+	args = append(args, "--", c.Rewrites.Apply(repoURL), filepath.Base(dir))
+	return c.run(filepath.Dir(dir), args...)
+}
+
+// Refuse refs that git would parse as an option.
+func checkRef(ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref '%s'", ref)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/acidlang/ace/cmds"
+)
+
+// A valid signature on a tag or commit.
+type Signature struct {
+	// "gpg" or "ssh".
+	Format string
+	// Fingerprint of the signing key: hex for GPG, "SHA256:<base64>" for SSH.
+	Key string
+	// Fingerprint of the GPG primary key when a subkey signed, otherwise "".
+	PrimaryKey string
+	// Who the key belongs to: a GPG user ID or an SSH principal.
+	Signer string
+}
+
+// Reported when a tag or commit carries no signature git could verify.
+var ErrUnsigned = errors.New("no valid signature")
+
+// Verify the signature on rev, an annotated tag or a commit, in the
+// repository at repoPath.
+//
+// GPG signatures are checked against the user's keyring. SSH signatures are
+// checked against sshKeys, public keys in authorized_keys format, or git's own
+// gpg.ssh.allowedSignersFile when none are given.
+func (c CLI) VerifySignature(repoPath, rev string, sshKeys []string) (Signature, error) {
+	if err := checkRef(rev); err != nil {
+		return Signature{}, err
+	}
+
+	var args []string
+	if len(sshKeys) > 0 {
+		file, err := allowedSignersFile(sshKeys)
+		if err != nil {
+			return Signature{}, err
+		}
+		defer os.Remove(file)
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+file)
+	}
+
+	kind := "commit"
+	if objectType, err := c.output(repoPath, "cat-file", "-t", rev); err == nil && objectType == "tag" {
+		kind = "tag"
+	}
+	args = append(args, "verify-"+kind, "--raw", rev)
+
+	output, err := cmds.RunCommandCombined(repoPath, c.environment(), c.Timeout, c.Path(), args...)
+	signature := parseSignature(output)
+	if err != nil || signature.Key == "" {
+		// git prints nothing at all for an unsigned commit.
+		if output == "" {
+			return Signature{}, fmt.Errorf("%w on %s %s", ErrUnsigned, kind, rev)
+		}
+		if err == nil {
+			err = errors.New("git reported no signing key")
+		}
+		return Signature{}, fmt.Errorf("%w on %s %s: %v", ErrUnsigned, kind, rev, err)
+	}
+	return signature, nil
+}
+
+// Read the signing key out of git's verify-tag/verify-commit --raw output:
+// GnuPG status lines, or ssh-keygen's "Good ... signature" line.
+func parseSignature(output string) Signature {
+	var signature Signature
+	for line := range strings.SplitSeq(output, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG":
+			signature.Format = "gpg"
+			signature.Key = fields[2]
+			if len(fields) >= 12 && fields[11] != fields[2] {
+				signature.PrimaryKey = fields[11]
+			}
+		case len(fields) >= 4 && fields[0] == "[GNUPG:]" && fields[1] == "GOODSIG":
+			signature.Signer = strings.Join(fields[3:], " ")
+		case strings.HasPrefix(line, `Good "git" signature for `):
+			// Good "git" signature for <principal> with <type> key SHA256:<base64>
+			rest := strings.TrimPrefix(line, `Good "git" signature for `)
+			principal, keyPart, found := strings.Cut(rest, " with ")
+			keyFields := strings.Fields(keyPart)
+			if found && len(keyFields) > 0 {
+				signature.Format = "ssh"
+				signature.Key = keyFields[len(keyFields)-1]
+				// The allowed signers file ace writes trusts its keys for any
+				// principal, "*".
+				if principal != "*" {
+					signature.Signer = principal
+				}
+			}
+		}
+	}
+	return signature
+}
+
+// Write an allowed signers file trusting sshKeys for any principal.
+func allowedSignersFile(sshKeys []string) (string, error) {
+	file, err := os.CreateTemp("", "ace-allowed-signers-*")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	for _, key := range sshKeys {
+		if _, err := fmt.Fprintf(file, "* %s\n", key); err != nil {
+			os.Remove(file.Name())
+			return "", err
+		}
+	}
+	return file.Name(), nil
+}
+
+// Get the "SHA256:<base64>" fingerprint of an SSH public key in
+// authorized_keys format, as ssh-keygen -l shows it, or false when key is not
+// one.
+func SSHFingerprint(key string) (string, bool) {
+	fields := strings.Fields(key)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "ssh-") && !strings.HasPrefix(fields[0], "ecdsa-") && !strings.HasPrefix(fields[0], "sk-") {
+		return "", false
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), true
+}
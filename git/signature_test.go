@@ -0,0 +1,149 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   Signature
+	}{
+		{
+			"gpg primary key",
+			"[GNUPG:] NEWSIG\n" +
+				"[GNUPG:] GOODSIG 0123456789ABCDEF Ada Lovelace <ada@example.org>\n" +
+				"[GNUPG:] VALIDSIG AAAA0123456789ABCDEF 2026-01-02 1767323045 0 4 0 22 10 00 AAAA0123456789ABCDEF\n",
+			Signature{Format: "gpg", Key: "AAAA0123456789ABCDEF", Signer: "Ada Lovelace <ada@example.org>"},
+		},
+		{
+			"gpg subkey",
+			"[GNUPG:] GOODSIG 0123456789ABCDEF Ada <ada@example.org>\n" +
+				"[GNUPG:] VALIDSIG BBBB0123456789ABCDEF 2026-01-02 1767323045 0 4 0 22 10 00 AAAA0123456789ABCDEF\n",
+			Signature{Format: "gpg", Key: "BBBB0123456789ABCDEF", PrimaryKey: "AAAA0123456789ABCDEF", Signer: "Ada <ada@example.org>"},
+		},
+		{
+			"gpg bad signature",
+			"[GNUPG:] BADSIG 0123456789ABCDEF Ada <ada@example.org>\n",
+			Signature{},
+		},
+		{
+			"ssh principal",
+			`Good "git" signature for ada@example.org with ED25519 key SHA256:abc+def/123` + "\n",
+			Signature{Format: "ssh", Key: "SHA256:abc+def/123", Signer: "ada@example.org"},
+		},
+		{
+			"ssh any principal",
+			`Good "git" signature for * with RSA key SHA256:xyz` + "\n",
+			Signature{Format: "ssh", Key: "SHA256:xyz"},
+		},
+		{
+			"ssh unknown key",
+			"Could not verify signature.\n",
+			Signature{},
+		},
+	}
+	for _, test := range tests {
+		if got := parseSignature(test.output); got != test.want {
+			t.Errorf("%s: parseSignature = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// Generate an ed25519 key pair in dir, returning the private key path and the
+// public key in authorized_keys format.
+func sshKey(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	path := filepath.Join(dir, name)
+	if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name, "-f", path).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, output)
+	}
+	public, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, strings.TrimSpace(string(public))
+}
+
+func TestSSHFingerprint(t *testing.T) {
+	path, public := sshKey(t, t.TempDir(), "ada")
+	output, err := exec.Command("ssh-keygen", "-l", "-f", path+".pub").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Fields(string(output))[1]
+
+	tests := []struct {
+		key         string
+		fingerprint string
+		ok          bool
+	}{
+		{public, want, true},
+		{strings.TrimSuffix(public, " ada"), want, true},
+		{"ssh-ed25519 not-base64!", "", false},
+		{"SHA256:" + strings.Repeat("a", 43), "", false},
+		{"0123456789ABCDEF", "", false},
+	}
+	for _, test := range tests {
+		fingerprint, ok := SSHFingerprint(test.key)
+		if fingerprint != test.fingerprint || ok != test.ok {
+			t.Errorf("SSHFingerprint(%q) = %q, %v; want %q, %v", test.key, fingerprint, ok, test.fingerprint, test.ok)
+		}
+	}
+}
+
+func TestVerifySSHSignature(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	dir := t.TempDir()
+	key, public := sshKey(t, dir, "ada")
+	_, other := sshKey(t, dir, "eve")
+	repo := filepath.Join(dir, "json")
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.name=Ada", "-c", "user.email=ada@example.org", "commit", "-q", "--allow-empty", "-m", "unsigned"},
+		{"-C", repo, "-c", "user.name=Ada", "-c", "user.email=ada@example.org", "-c", "gpg.format=ssh", "-c", "user.signingkey=" + key, "tag", "-s", "-m", "v1.0.0", "v1.0.0"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	fingerprint, _ := SSHFingerprint(public)
+
+	tests := []struct {
+		name    string
+		rev     string
+		keys    []string
+		key     string
+		invalid bool
+	}{
+		{"allowed key", "v1.0.0", []string{other, public}, fingerprint, false},
+		{"other key", "v1.0.0", []string{other}, "", true},
+		{"unsigned commit", "HEAD", []string{public}, "", true},
+		{"option as revision", "--help", []string{public}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signature, err := CLI{}.VerifySignature(repo, test.rev, test.keys)
+			if (err != nil) != test.invalid || signature.Key != test.key {
+				t.Errorf("VerifySignature = %+v, %v; want key %q", signature, err, test.key)
+			}
+			if test.rev == "HEAD" && !errors.Is(err, ErrUnsigned) {
+				t.Errorf("VerifySignature of an unsigned commit = %v, want ErrUnsigned", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Package license identifies the licenses of module sources by SPDX
+// identifier and checks them against an allow/deny policy.
+package license
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Files a license is looked for in, compared case-insensitively.
+var fileNames = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "LICENCE.md", "LICENCE.txt", "COPYING", "COPYING.md", "COPYING.txt"}
+
+// Phrases identifying common licenses, most specific first. A license
+// matches when its text contains every one of them.
+var signatures = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"GNU AFFERO GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-3.0", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"LGPL-2.1", []string{"GNU LESSER GENERAL PUBLIC LICENSE", "Version 2.1"}},
+	{"GPL-3.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 3"}},
+	{"GPL-2.0", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary forms", "Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary forms"}},
+	{"ISC", []string{"Permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"Unlicense", []string{"This is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"CC0 1.0 Universal"}},
+}
+
+var spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([^\s*/][^\r\n*/]*)`)
+
+// Detect the license of the sources in dir from its license file: an
+// SPDX-License-Identifier tag, or else the text of a well known license.
+//
+// Returns the SPDX identifier and the file it came from, or "" for both when
+// there is no license file, and "" with the file when its text is not
+// recognized.
+func Detect(dir string) (string, string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", ""
+	}
+
+	for _, name := range fileNames {
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.EqualFold(entry.Name(), name) {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			return Identify(string(content)), entry.Name()
+		}
+	}
+	return "", ""
+}
+
+// Identify a license text by SPDX identifier, or "" when it is not
+// recognized.
+func Identify(text string) string {
+	if match := spdxTag.FindStringSubmatch(text); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+
+	// Normalize line breaks and indentation, which vary between copies.
+	text = strings.Join(strings.Fields(text), " ")
+	for _, signature := range signatures {
+		if containsAll(text, signature.phrases) {
+			return signature.id
+		}
+	}
+	return ""
+}
+
+func containsAll(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(strings.ToLower(text), strings.ToLower(phrase)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Which licenses a project accepts, by SPDX identifier. The zero value
+// accepts everything.
+type Policy struct {
+	// When set, only these licenses are accepted, and unknown ones are not.
+	Allow []string
+	// Never accepted, even when also allowed.
+	Deny []string
+}
+
+// Check whether the policy has any rules.
+func (policy Policy) Enabled() bool {
+	return len(policy.Allow) > 0 || len(policy.Deny) > 0
+}
+
+// Check whether the policy accepts a license, given as an SPDX identifier or
+// expression: "A OR B" needs one of them accepted, "A AND B" both, AND binds
+// tighter than OR, and parentheses group. "A WITH exception" is accepted
+// along with A, unless it is denied itself.
+//
+// An unknown ("") license is accepted only when there is no allow list, and
+// an expression that does not parse is never accepted.
+func (policy Policy) Allows(expression string) bool {
+	if strings.TrimSpace(expression) == "" {
+		return len(policy.Allow) == 0
+	}
+	parsed, err := parse(expression)
+	if err != nil {
+		return false
+	}
+	return parsed.allowedBy(policy)
+}
+
+// Check whether a license is a well formed SPDX identifier or expression.
+func Valid(expression string) bool {
+	_, err := parse(expression)
+	return err == nil
+}
+
+// A parsed license expression.
+type node interface {
+	allowedBy(policy Policy) bool
+}
+
+// A license, optionally with an exception, e.g. GPL-2.0 WITH
+// Classpath-exception-2.0.
+type identifier struct {
+	license   string
+	exception string
+}
+
+func (id identifier) allowedBy(policy Policy) bool {
+	if id.exception == "" {
+		return policy.allowsID(id.license)
+	}
+	full := id.license + " WITH " + id.exception
+	if containsFold(policy.Deny, full) {
+		return false
+	}
+	return policy.allowsID(full) || policy.allowsID(id.license)
+}
+
+// Licenses joined by AND (all of them) or OR (any of them).
+type compound struct {
+	and      bool
+	operands []node
+}
+
+func (c compound) allowedBy(policy Policy) bool {
+	for _, operand := range c.operands {
+		if operand.allowedBy(policy) != c.and {
+			return !c.and
+		}
+	}
+	return c.and
+}
+
+func (policy Policy) allowsID(id string) bool {
+	if containsFold(policy.Deny, id) {
+		return false
+	}
+	return len(policy.Allow) == 0 || containsFold(policy.Allow, id)
+}
+
+// Parse a license expression:
+//
+//	or   = and { "OR" and }
+//	and  = term { "AND" term }
+//	term = "(" or ")" | license [ "WITH" exception ]
+func parse(expression string) (node, error) {
+	expression = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)
+	p := &parser{tokens: strings.Fields(expression)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty license expression")
+	}
+
+	parsed, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in license expression", p.tokens[p.pos])
+	}
+	return parsed, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) or() (node, error) {
+	return p.sequence("OR", p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.sequence("AND", p.term)
+}
+
+// Parse operands joined by operator.
+func (p *parser) sequence(operator string, operand func() (node, error)) (node, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []node{first}
+	for p.next() == operator {
+		p.pos++
+		parsed, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, parsed)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return compound{and: operator == "AND", operands: operands}, nil
+}
+
+func (p *parser) term() (node, error) {
+	token := p.next()
+	p.pos++
+	switch token {
+	case "(":
+		parsed, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("unbalanced parentheses in license expression")
+		}
+		p.pos++
+		return parsed, nil
+	case "", ")", "AND", "OR", "WITH":
+		return nil, fmt.Errorf("expected a license, found '%s'", token)
+	}
+
+	id := identifier{license: token}
+	if p.next() == "WITH" {
+		p.pos++
+		id.exception = p.next()
+		if !isName(id.exception) {
+			return nil, fmt.Errorf("expected an exception after WITH, found '%s'", id.exception)
+		}
+		p.pos++
+	}
+	return id, nil
+}
+
+func isName(token string) bool {
+	return token != "" && token != "(" && token != ")" && token != "AND" && token != "OR" && token != "WITH"
+}
+
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, value) })
+}
@@ -0,0 +1,100 @@
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	permissive := Policy{Allow: []string{"MIT", "Apache-2.0", "BSD-3-Clause"}}
+	noGPL := Policy{Deny: []string{"GPL-3.0", "GPL-2.0 WITH Classpath-exception-2.0"}}
+
+	tests := []struct {
+		policy     Policy
+		expression string
+		want       bool
+	}{
+		{Policy{}, "", true},
+		{Policy{}, "Anything-1.0", true},
+		{permissive, "", false},
+		{noGPL, "", true},
+
+		{permissive, "MIT", true},
+		{permissive, "mit", true},
+		{permissive, "GPL-3.0", false},
+		{noGPL, "GPL-3.0", false},
+		{noGPL, "MIT", true},
+
+		{permissive, "MIT OR GPL-3.0", true},
+		{permissive, "MIT AND GPL-3.0", false},
+		{noGPL, "MIT OR GPL-3.0", true},
+		{noGPL, "MIT AND GPL-3.0", false},
+
+		// AND binds tighter than OR.
+		{permissive, "GPL-3.0 OR MIT AND Apache-2.0", true},
+		{permissive, "MIT AND GPL-3.0 OR Apache-2.0", true},
+		{permissive, "MIT AND (GPL-3.0 OR Apache-2.0)", true},
+		{permissive, "(MIT OR GPL-3.0) AND GPL-3.0", false},
+		{permissive, "(MIT OR Apache-2.0) AND BSD-3-Clause", true},
+		{permissive, "((MIT OR Apache-2.0)) AND (BSD-3-Clause)", true},
+		{permissive, "(MIT OR Apache-2.0) AND LGPL-3.0", false},
+
+		// Malformed expressions are never accepted.
+		{permissive, "MIT OR Apache-2.0) AND BSD-3-Clause", false},
+		{permissive, "(MIT OR Apache-2.0", false},
+		{permissive, "MIT OR", false},
+		{permissive, "AND MIT", false},
+		{permissive, "MIT Apache-2.0", false},
+		{Policy{}, "MIT OR", false},
+
+		// An exception is accepted along with its license, unless denied.
+		{permissive, "Apache-2.0 WITH LLVM-exception", true},
+		{Policy{Allow: []string{"GPL-2.0 WITH Classpath-exception-2.0"}}, "GPL-2.0 WITH Classpath-exception-2.0", true},
+		{Policy{Allow: []string{"GPL-2.0 WITH Classpath-exception-2.0"}}, "GPL-2.0", false},
+		{noGPL, "GPL-2.0 WITH Classpath-exception-2.0", false},
+		{noGPL, "GPL-2.0", true},
+		{permissive, "MIT WITH", false},
+		{permissive, "MIT WITH OR Apache-2.0", false},
+	}
+	for _, test := range tests {
+		if got := test.policy.Allows(test.expression); got != test.want {
+			t.Errorf("%+v.Allows(%q) = %v, want %v", test.policy, test.expression, got, test.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for expression, want := range map[string]bool{
+		"MIT":                                  true,
+		"MIT OR Apache-2.0":                    true,
+		"(MIT OR Apache-2.0) AND BSD-3-Clause": true,
+		"GPL-2.0 WITH Classpath-exception-2.0": true,
+		"":                                     false,
+		"()":                                   false,
+		"MIT OR Apache-2.0) AND BSD-3-Clause":  false,
+		"MIT AND AND Apache-2.0":               false,
+		"(MIT OR Apache-2.0) BSD-3-Clause":     false,
+	} {
+		if got := Valid(expression); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", expression, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	if id, file := Detect(dir); id != "" || file != "" {
+		t.Errorf("Detect(empty) = %q, %q", id, file)
+	}
+
+	os.WriteFile(filepath.Join(dir, "license.md"), []byte("MIT License\n\nPermission is hereby granted,\n  free of charge, to any person"), 0644)
+	if id, file := Detect(dir); id != "MIT" || file != "license.md" {
+		t.Errorf("Detect = %q, %q; want MIT from license.md", id, file)
+	}
+
+	os.WriteFile(filepath.Join(dir, "license.md"), []byte("// SPDX-License-Identifier: MIT OR Apache-2.0\n"), 0644)
+	if id, _ := Detect(dir); id != "MIT OR Apache-2.0" {
+		t.Errorf("Detect = %q, want the SPDX tag", id)
+	}
+}
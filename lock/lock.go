@@ -1,180 +1,143 @@
 package lock
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/acidlang/ace/texts"
+	"github.com/acidlang/ace/source"
 )
 
 type LockEntry struct {
-	Repo             string   `json:"repo"`
-	Timestamp        string   `json:"timestamp"`
-	CommitHash       string   `json:"commit_hash"`
-	RequestedVersion string   `json:"requested_version"`
-	Branch           string   `json:"branch"`
-	Tags             []string `json:"tags"`
+	Repo             string `json:"repo"`
+	Timestamp        string `json:"timestamp"`
+	CommitHash       string `json:"commit_hash"`
+	RequestedVersion string `json:"requested_version"`
+	Branch           string `json:"branch"`
+	// The fields below are optional, and left out of acid.lock when empty so
+	// that a lockfile only mentions the features a module uses.
+	Tags    []string `json:"tags,omitempty"`
+	Replace string   `json:"replace,omitempty"`
+	// Content hash of a module installed from an archive, which has no commit.
+	Checksum string `json:"checksum,omitempty"`
+	// Fingerprint of the key whose signature on the tag or commit was
+	// verified, for modules that require one.
+	Signer string `json:"signer,omitempty"`
 }
 
 type LockFile map[string]LockEntry
 
+var ErrFrozen = errors.New("acid.lock is frozen and cannot be modified (--frozen or ACE_FROZEN is set)")
+
+// Check whether ACE_FROZEN=1 turns frozen mode on. Callers refuse to write
+// the lockfile and acid.sum while frozen; the functions here do not check.
+func IsFrozen() bool {
+	value := strings.ToLower(os.Getenv("ACE_FROZEN"))
+	return value == "1" || value == "true" || value == "yes"
+}
+
 // Parse some lockfile given the filename.
 //
-// Returns the lockfile instance.
+// Returns the lockfile instance. An empty file is an empty lockfile.
 func ParseLockFile(filename string) (LockFile, error) {
 	lockFile := make(LockFile)
 
-	file, err := os.Open(filename)
+	content, err := os.ReadFile(filename)
 	if err != nil {
 		return lockFile, err
 	}
-	defer file.Close()
-
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return lockFile, err
+	if len(bytes.TrimSpace(content)) == 0 {
+		return lockFile, nil
 	}
 
-	lines := strings.Split(string(content), "\n")
-	var currentModule string
-	var currentEntry LockEntry
-
-	fieldNames := map[string]bool{
-		"repo":              true,
-		"timestamp":         true,
-		"commit_hash":       true,
-		"requested_version": true,
-		"branch":            true,
-		"tags":              true,
+	if err := json.Unmarshal(content, &lockFile); err != nil {
+		return make(LockFile), fmt.Errorf("invalid lockfile %s: %v", filename, err)
 	}
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			continue
-		}
-
-		if strings.Contains(line, "\":") {
-			fieldName := texts.ExtractString(line, "\"", "\":")
-
-			if !fieldNames[fieldName] {
-				if currentModule != "" {
-					lockFile[currentModule] = currentEntry
-				}
-				currentModule = fieldName
-				currentEntry = LockEntry{}
-			} else {
-				switch fieldName {
-				case "repo":
-					currentEntry.Repo = texts.ExtractString(line, "\"repo\": \"", "\"")
-				case "timestamp":
-					currentEntry.Timestamp = texts.ExtractString(line, "\"timestamp\": \"", "\"")
-				case "commit_hash":
-					currentEntry.CommitHash = texts.ExtractString(line, "\"commit_hash\": \"", "\"")
-				case "requested_version":
-					currentEntry.RequestedVersion = texts.ExtractString(line, "\"requested_version\": \"", "\"")
-				case "branch":
-					currentEntry.Branch = texts.ExtractString(line, "\"branch\": \"", "\"")
-				case "tags":
-					tagsStr := texts.ExtractString(line, "\"tags\": [", "]")
-					if tagsStr != "" {
-						tags := strings.Split(tagsStr, ",")
-						for i, tag := range tags {
-							tags[i] = strings.Trim(strings.TrimSpace(tag), "\"")
-						}
-						currentEntry.Tags = tags
-					}
-				}
-			}
-		}
-	}
-
-	if currentModule != "" {
-		lockFile[currentModule] = currentEntry
-	}
-
 	return lockFile, nil
 }
 
 // Write to the lockfile given the filename and the lockfile instance,
 // (Not a pointer to it, the instance copy itself).
+//
+// Modules are written sorted by name, and credentials embedded in repository
+// URLs are never written.
 func WriteLockFile(filename string, lockFile LockFile) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	stripped := make(LockFile, len(lockFile))
+	for moduleName, entry := range lockFile {
+		entry.Repo = source.StripCredentials(entry.Repo)
+		entry.Replace = source.StripCredentials(entry.Replace)
+		stripped[moduleName] = entry
 	}
-	defer file.Close()
 
-	file.WriteString("{\n")
-	i := 0
-	for moduleName, entry := range lockFile {
-		if i > 0 {
-			file.WriteString(",\n")
-		}
-		fmt.Fprintf(file, "  \"%s\": {\n", moduleName)
-		fmt.Fprintf(file, "    \"repo\": \"%s\",\n", entry.Repo)
-		fmt.Fprintf(file, "    \"timestamp\": \"%s\",\n", entry.Timestamp)
-		fmt.Fprintf(file, "    \"commit_hash\": \"%s\",\n", entry.CommitHash)
-		fmt.Fprintf(file, "    \"requested_version\": \"%s\",\n", entry.RequestedVersion)
-		fmt.Fprintf(file, "    \"branch\": \"%s\"", entry.Branch)
-
-		if len(entry.Tags) > 0 {
-			file.WriteString(",\n    \"tags\": [")
-			for j, tag := range entry.Tags {
-				if j > 0 {
-					file.WriteString(", ")
-				}
-				file.WriteString(fmt.Sprintf("\"%s\"", tag))
-			}
-			file.WriteString("]")
-		}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(stripped); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
 
-		file.WriteString("\n  }")
-		i++
+// Record an entry for the given module in a lockfile, stamping it with the current time.
+func UpdateLockFile(filename, moduleName string, entry LockEntry) error {
+	lockFile, err := ParseLockFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	file.WriteString("\n}\n")
 
-	return nil
+	entry.Timestamp = time.Now().Format("2006-01-02T15:04:05")
+	lockFile[moduleName] = entry
+	return WriteLockFile(filename, lockFile)
 }
 
-func UpdateLockFile(moduleName, repoURL, commitHash, requestedVersion string, tags []string, branch string) error {
-	lockFile, err := ParseLockFile("acid.lock")
+func RemoveFromLockFile(filename, moduleName string) error {
+	lockFile, err := ParseLockFile(filename)
 	if err != nil {
-		lockFile = make(LockFile)
+		return err
 	}
 
-	entry := LockEntry{
-		Repo:             repoURL,
-		Timestamp:        time.Now().Format("2006-01-02T15:04:05"),
-		CommitHash:       commitHash,
-		RequestedVersion: requestedVersion,
-		Branch:           branch,
-		Tags:             tags,
+	if _, exists := lockFile[moduleName]; !exists {
+		return fmt.Errorf("module %s not found in lock file", moduleName)
 	}
 
-	lockFile[moduleName] = entry
-	return WriteLockFile("acid.lock", lockFile)
+	delete(lockFile, moduleName)
+	return WriteLockFile(filename, lockFile)
 }
 
-func RemoveFromLockFile(moduleName string) error {
-	lockFile, err := ParseLockFile("acid.lock")
+// Move a lock entry to a new module name, keeping its recorded details.
+func RenameLockEntry(filename, oldName, newName string) error {
+	lockFile, err := ParseLockFile(filename)
 	if err != nil {
-		fmt.Println("No acid.lock found.")
 		return err
 	}
 
-	if _, exists := lockFile[moduleName]; !exists {
-		fmt.Printf("Module %s not found in lock file.\n", moduleName)
-		return fmt.Errorf("module not found")
+	entry, exists := lockFile[oldName]
+	if !exists {
+		return fmt.Errorf("module %s not found in lock file", oldName)
+	}
+	if _, taken := lockFile[newName]; taken && newName != oldName {
+		return fmt.Errorf("module %s is already in lock file", newName)
 	}
 
-	delete(lockFile, moduleName)
-	err = WriteLockFile("acid.lock", lockFile)
-	if err == nil {
-		fmt.Printf("Removed %s from lock file.\n", moduleName)
+	delete(lockFile, oldName)
+	lockFile[newName] = entry
+	return WriteLockFile(filename, lockFile)
+}
+
+// Find the module name a repository is locked under.
+//
+// The repository URL is a module's identity, so this still finds a module
+// whose declared name changed upstream, however its URL is spelled.
+func (lockFile LockFile) FindByRepo(repoURL string) (string, bool) {
+	for moduleName, entry := range lockFile {
+		if source.Same(entry.Repo, repoURL) {
+			return moduleName, true
+		}
 	}
-	return err
+	return "", false
 }
@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/acidlang/ace/source"
+)
+
+// A module version recorded in acid.sum: the module's canonical repository
+// and a tag or full commit hash.
+type SumKey struct {
+	Module  string
+	Version string
+}
+
+// The checksum database, acid.sum: the content hash of every module version
+// the project has installed, one "<module> <version> sha256:<hex>" line each.
+//
+// It is only ever added to, so a version whose content changes upstream is
+// caught on every machine sharing the file.
+type SumFile map[SumKey]string
+
+// Get the module path a repository is recorded under in acid.sum, e.g.
+// github.com/acidlang/json for any spelling of its URL.
+func SumModule(repoURL string) string {
+	return filepath.ToSlash(source.Canonical(repoURL))
+}
+
+// Parse the checksum database at filename.
+func ParseSumFile(filename string) (SumFile, error) {
+	sums := make(SumFile)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return sums, err
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || !strings.HasPrefix(fields[2], "sha256:") {
+			return sums, fmt.Errorf("%s:%d: expected '<module> <version> sha256:<hex>'", filename, i+1)
+		}
+		sums[SumKey{Module: fields[0], Version: fields[1]}] = fields[2]
+	}
+	return sums, nil
+}
+
+// Write the checksum database to filename, sorted so that it diffs cleanly.
+func WriteSumFile(filename string, sums SumFile) error {
+	keys := make([]SumKey, 0, len(sums))
+	for key := range sums {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Module != keys[j].Module {
+			return keys[i].Module < keys[j].Module
+		}
+		return keys[i].Version < keys[j].Version
+	})
+
+	var content strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s %s %s\n", key.Module, key.Version, sums[key])
+	}
+	return os.WriteFile(filename, []byte(content.String()), 0644)
+}
@@ -0,0 +1,83 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSumFileRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "acid.sum")
+	sums := SumFile{
+		{Module: "github.com/acidlang/json", Version: "v1.1.0"}:                sumOf("b"),
+		{Module: "github.com/acidlang/json", Version: strings.Repeat("c", 40)}: sumOf("b"),
+		{Module: "example.org/acid/http", Version: "v0.2.0"}:                   sumOf("d"),
+	}
+
+	if err := WriteSumFile(filename, sums); err != nil {
+		t.Fatalf("WriteSumFile: %v", err)
+	}
+	content, _ := os.ReadFile(filename)
+	want := "example.org/acid/http v0.2.0 " + sumOf("d") + "\n" +
+		"github.com/acidlang/json " + strings.Repeat("c", 40) + " " + sumOf("b") + "\n" +
+		"github.com/acidlang/json v1.1.0 " + sumOf("b") + "\n"
+	if string(content) != want {
+		t.Errorf("acid.sum =\n%s\nwant sorted lines\n%s", content, want)
+	}
+
+	parsed, err := ParseSumFile(filename)
+	if err != nil {
+		t.Fatalf("ParseSumFile: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, sums) {
+		t.Errorf("ParseSumFile = %v, want %v", parsed, sums)
+	}
+}
+
+func TestParseSumFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		sums    int
+		valid   bool
+	}{
+		{"empty", "", 0, true},
+		{"blank lines", "\n  \ngithub.com/acidlang/json v1.0.0 " + sumOf("a") + "\n\n", 1, true},
+		{"missing hash", "github.com/acidlang/json v1.0.0\n", 0, false},
+		{"bare hex", "github.com/acidlang/json v1.0.0 " + strings.Repeat("a", 64) + "\n", 0, false},
+		{"extra field", "github.com/acidlang/json v1.0.0 " + sumOf("a") + " extra\n", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "acid.sum")
+			os.WriteFile(filename, []byte(test.content), 0644)
+
+			sums, err := ParseSumFile(filename)
+			if (err == nil) != test.valid {
+				t.Fatalf("ParseSumFile = %v, want valid %v", err, test.valid)
+			}
+			if test.valid && len(sums) != test.sums {
+				t.Errorf("ParseSumFile = %v, want %d entries", sums, test.sums)
+			}
+		})
+	}
+}
+
+func TestSumModule(t *testing.T) {
+	for _, url := range []string{
+		"https://github.com/acidlang/json",
+		"https://github.com/acidlang/json.git",
+		"git@github.com:acidlang/json.git",
+		"ssh://git@github.com/acidlang/json",
+	} {
+		if got := SumModule(url); got != "github.com/acidlang/json" {
+			t.Errorf("SumModule(%q) = %q, want github.com/acidlang/json", url, got)
+		}
+	}
+}
+
+func sumOf(digit string) string {
+	return "sha256:" + strings.Repeat(digit, 64)
+}
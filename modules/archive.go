@@ -0,0 +1,141 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acidlang/ace/archive"
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/registry"
+)
+
+// Install a module from a .tar.gz or .zip archive URL.
+//
+// Archives have no commit, so the lock entry pins the hash of the extracted
+// contents instead. Outside a registry, version may only be the "sha256:"
+// checksum the downloaded file must match.
+func (p *Project) installArchive(url, version string, published registry.Version, hasPublished bool) (Module, error) {
+	fileSum := ""
+	if !hasPublished && version != "" {
+		if !strings.HasPrefix(version, "sha256:") {
+			return Module{}, fmt.Errorf("%w: archives are pinned by their URL, only a sha256:<hex> checksum may follow '@'", ErrInvalidConfig)
+		}
+		fileSum = version
+		version = ""
+	}
+
+	p.logf("Downloading %s...", url)
+
+	dir := p.resolve("tmp_" + archive.Name(url))
+	config, sum, err := p.fetchArchive(url, dir, fileSum)
+	if err != nil {
+		return Module{}, err
+	}
+
+	if hasPublished && published.Checksum != "" && !checksum.Equal(sum, published.Checksum) {
+		os.RemoveAll(dir)
+		return Module{}, fmt.Errorf("%w: checksum mismatch for %s: got %s, registry lists %s", ErrVerificationFailed, published.Version, sum, published.Checksum)
+	}
+
+	if err := p.CheckNameClash(config.Name, url); err != nil {
+		os.RemoveAll(dir)
+		return Module{}, err
+	}
+	if err := p.checkLicense(config.Name, dir); err != nil {
+		os.RemoveAll(dir)
+		return Module{}, err
+	}
+	if err := p.checkRequirements(config.Name, dir, config); err != nil {
+		os.RemoveAll(dir)
+		return Module{}, err
+	}
+	p.DropRenamedModule(url, config.Name)
+
+	targetDir := p.ModuleDir(config.Name)
+	if err := p.moveInto(dir, targetDir); err != nil {
+		return Module{}, err
+	}
+	p.logf("Saved module to %s", targetDir)
+
+	entry := lock.LockEntry{Repo: url, RequestedVersion: version, Checksum: sum}
+	if err := p.updateLock(config.Name, entry); err != nil {
+		return Module{}, fmt.Errorf("error updating lockfile: %w", err)
+	}
+	p.logf("Lockfile updated with checksum %s.", sum)
+	return p.module(config.Name, entry), nil
+}
+
+// Restore a module locked from an archive, which must still extract to the
+// locked contents. replacement is the replace directive in effect, if any.
+func (p *Project) restoreArchive(moduleName, url string, entry lock.LockEntry, replacement string) (Module, error) {
+	p.logf("Restoring %s from %s", moduleName, url)
+
+	dir := p.resolve("tmp_" + moduleName)
+	config, sum, err := p.fetchArchive(url, dir, "")
+	if err != nil {
+		return Module{}, err
+	}
+
+	sameSource := p.options.Rewrites.Same(url, replacedSource(entry))
+	if sameSource && entry.Checksum != "" && !checksum.Equal(sum, entry.Checksum) {
+		os.RemoveAll(dir)
+		return Module{}, fmt.Errorf("%w: %s changed since it was locked: got %s, %s pins %s", ErrVerificationFailed, url, sum, p.lockName, entry.Checksum)
+	}
+
+	targetDir := p.ModuleDir(moduleName)
+	if err := p.moveInto(dir, targetDir); err != nil {
+		return Module{}, err
+	}
+	p.logf("Restored %s to %s", moduleName, targetDir)
+
+	module := Module{Name: moduleName, Dir: targetDir, Config: &config, LockEntry: entry}
+	if !sameSource || entry.Checksum == "" {
+		newEntry := lock.LockEntry{Repo: entry.Repo, RequestedVersion: entry.RequestedVersion, Replace: replacement, Checksum: sum}
+		module.LockEntry = newEntry
+		if err := p.updateLock(moduleName, newEntry); err != nil {
+			return module, fmt.Errorf("error updating lockfile: %w", err)
+		}
+	}
+	return module, nil
+}
+
+// Download and extract an archive into dir, returning its module
+// configuration and content hash.
+func (p *Project) fetchArchive(url, dir, fileSum string) (ModuleConfig, string, error) {
+	os.RemoveAll(dir)
+	client := archive.Client{Timeout: p.options.Timeout, Rewrites: p.options.Rewrites}
+	if err := client.Fetch(url, dir, fileSum); err != nil {
+		if errors.Is(err, archive.ErrChecksumMismatch) {
+			return ModuleConfig{}, "", fmt.Errorf("%w: %v", ErrVerificationFailed, err)
+		}
+		return ModuleConfig{}, "", fmt.Errorf("%w: error downloading %s: %v", ErrCloneFailed, url, err)
+	}
+
+	config, err := ParseModuleConfig(filepath.Join(dir, "module.acidcfg"))
+	if err != nil {
+		os.RemoveAll(dir)
+		if os.IsNotExist(err) {
+			return ModuleConfig{}, "", fmt.Errorf("%w: no module.acidcfg found in %s", ErrInvalidConfig, url)
+		}
+		return ModuleConfig{}, "", fmt.Errorf("%w: error parsing module config: %v", ErrInvalidConfig, err)
+	}
+
+	sum, err := checksum.Dir(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return ModuleConfig{}, "", fmt.Errorf("could not checksum %s: %v", dir, err)
+	}
+	return config, sum, nil
+}
+
+// Shorten a "sha256:<hex>" content hash for display.
+func shortChecksum(sum string) string {
+	if len(sum) > len("sha256:")+12 {
+		return sum[:len("sha256:")+12]
+	}
+	return sum
+}
@@ -0,0 +1,128 @@
+package modules
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/acidlang/ace/advisory"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/semver"
+)
+
+// A locked module affected by an advisory.
+type Finding struct {
+	Module string
+	// The locked version the advisory matched, or "" when it matched the
+	// commit alone.
+	Version  string
+	Entry    lock.LockEntry
+	Advisory advisory.Advisory
+}
+
+// Check every lock entry against an advisory database, by its version and
+// commit.
+//
+// Findings are sorted by module, then by severity, most serious first.
+// Modules replaced by a local path track a working copy and are skipped.
+func (p *Project) Audit(db advisory.Database) ([]Finding, error) {
+	lockFile, err := p.readLock()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		entry := lockFile[moduleName]
+		if entry.Replace != "" && p.IsLocalReplacement(entry.Replace) {
+			continue
+		}
+
+		version := lockedVersion(entry)
+		var affecting []Finding
+		for _, adv := range db.For(moduleName, entry.Repo) {
+			if adv.Affects(version, entry.CommitHash) {
+				affecting = append(affecting, Finding{Module: moduleName, Version: version, Entry: entry, Advisory: adv})
+			}
+		}
+		slices.SortStableFunc(affecting, func(a, b Finding) int {
+			return advisory.SeverityRank(b.Advisory.Severity) - advisory.SeverityRank(a.Advisory.Severity)
+		})
+		findings = append(findings, affecting...)
+	}
+	return findings, nil
+}
+
+// Upgrade each module with findings to the lowest version above its own
+// that no advisory in db affects.
+//
+// Returns the upgraded modules, and an *OperationError listing the modules
+// with no such version or whose install failed.
+func (p *Project) FixAdvisories(db advisory.Database, findings []Finding) ([]Module, error) {
+	var (
+		fixed   []Module
+		summary = failureSummary{operation: "audit fix"}
+		seen    = make(map[string]bool)
+	)
+
+	for _, finding := range findings {
+		if seen[finding.Module] {
+			continue
+		}
+		seen[finding.Module] = true
+
+		version, err := safeVersion(db, finding)
+		if err == nil {
+			p.logf("Upgrading %s to %s", finding.Module, version)
+			var module Module
+			module, err = p.Install(finding.Entry.Repo, version)
+			if err == nil {
+				fixed = append(fixed, module)
+			}
+		}
+		summary.add(finding.Module, err)
+	}
+	return fixed, summary.err()
+}
+
+// Find the lowest fixed version of a module that is newer than the locked
+// one and affected by none of the module's advisories.
+func safeVersion(db advisory.Database, finding Finding) (string, error) {
+	if finding.Entry.Checksum != "" && finding.Entry.CommitHash == "" {
+		return "", fmt.Errorf("installed from an archive, install a fixed archive by hand")
+	}
+
+	advisories := db.For(finding.Module, finding.Entry.Repo)
+	current, hasCurrent := semver.Parse(finding.Version)
+
+	var candidates []string
+	for _, adv := range advisories {
+		for _, version := range adv.FixedVersions() {
+			v, _ := semver.Parse(version)
+			if (!hasCurrent || semver.Compare(v, current) > 0) && !slices.Contains(candidates, version) {
+				candidates = append(candidates, version)
+			}
+		}
+	}
+	advisory.SortVersions(candidates)
+
+	for _, candidate := range candidates {
+		if !slices.ContainsFunc(advisories, func(adv advisory.Advisory) bool { return adv.Affects(candidate, "") }) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no released version fixes every advisory (%s)", finding.Advisory.ID)
+}
+
+// Get the semantic version a lock entry is at: its requested version, or
+// else a tag on its commit.
+func lockedVersion(entry lock.LockEntry) string {
+	if semver.Valid(entry.RequestedVersion) {
+		return entry.RequestedVersion
+	}
+	for _, tag := range entry.Tags {
+		if semver.Valid(tag) {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/acidlang/ace/lock"
+)
+
+// A lock entry whose key disagrees with the name its module declares in
+// module.acidcfg, or with the pkg/ directory it is installed in.
+type NameMismatch struct {
+	LockKey      string
+	DeclaredName string
+	Dir          string
+	Repo         string
+}
+
+func (m NameMismatch) String() string {
+	return fmt.Sprintf("lock entry '%s' (%s) is installed in %s, which declares module name '%s'", m.LockKey, m.Repo, m.Dir, m.DeclaredName)
+}
+
+// Find every lock entry whose key, declared module name and directory disagree.
+//
+// The repository URL is treated as a module's identity, so a module that was
+// renamed upstream is still matched to its lock entry.
+func (p *Project) FindNameMismatches() []NameMismatch {
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		return nil
+	}
+
+	var mismatches []NameMismatch
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		entry := lockFile[moduleName]
+
+		dir := p.ModuleDir(moduleName)
+		if _, err := os.Stat(dir); err != nil {
+			if dir = p.findModuleDir(lockFile, entry); dir == "" {
+				continue
+			}
+		}
+
+		config, err := ParseModuleConfig(filepath.Join(dir, "module.acidcfg"))
+		if err != nil {
+			continue
+		}
+
+		if config.Name != moduleName || filepath.Base(dir) != moduleName {
+			mismatches = append(mismatches, NameMismatch{
+				LockKey:      moduleName,
+				DeclaredName: config.Name,
+				Dir:          dir,
+				Repo:         entry.Repo,
+			})
+		}
+	}
+	return mismatches
+}
+
+// Log a warning for every name mismatch in the project.
+func (p *Project) WarnNameMismatches() {
+	for _, mismatch := range p.FindNameMismatches() {
+		p.logf("Warning: %s, run 'ace doctor' to reconcile", mismatch)
+	}
+}
+
+// Move the module to the directory of its declared name and re-key its lock
+// entry to match.
+func (p *Project) ReconcileNameMismatch(mismatch NameMismatch) error {
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		return err
+	}
+	if other, taken := lockFile[mismatch.DeclaredName]; taken && mismatch.DeclaredName != mismatch.LockKey {
+		return fmt.Errorf("module name '%s' is already locked for %s", mismatch.DeclaredName, other.Repo)
+	}
+
+	targetDir := p.ModuleDir(mismatch.DeclaredName)
+	if mismatch.Dir != targetDir {
+		if _, err := os.Lstat(targetDir); err == nil {
+			return fmt.Errorf("%s already exists", targetDir)
+		}
+		if err := os.Rename(mismatch.Dir, targetDir); err != nil {
+			return err
+		}
+		p.logf("Moved %s to %s", mismatch.Dir, targetDir)
+	}
+
+	if mismatch.LockKey != mismatch.DeclaredName {
+		if err := p.renameLockEntry(mismatch.LockKey, mismatch.DeclaredName); err != nil {
+			return err
+		}
+		p.logf("Renamed lock entry %s to %s", mismatch.LockKey, mismatch.DeclaredName)
+	}
+	return nil
+}
+
+// Remove a module that is locked for repoURL under a name other than
+// moduleName, i.e. one that has since been renamed upstream.
+func (p *Project) DropRenamedModule(repoURL, moduleName string) {
+	oldName, found := p.lockFile().FindByRepo(repoURL)
+	if !found || oldName == moduleName {
+		return
+	}
+
+	p.logf("Module %s was renamed to %s upstream, replacing it", oldName, moduleName)
+	if err := ValidateModuleName(oldName); err == nil {
+		os.RemoveAll(p.ModuleDir(oldName))
+	}
+	p.removeFromLock(oldName)
+}
+
+// Find the installed directory holding a lock entry's module when it is not
+// named after the lock key, by matching the repository it was fetched from.
+func (p *Project) findModuleDir(lockFile lock.LockFile, entry lock.LockEntry) string {
+	dirs, err := os.ReadDir(p.PkgDir())
+	if err != nil {
+		return ""
+	}
+
+	for _, d := range dirs {
+		if _, locked := lockFile[d.Name()]; locked {
+			continue
+		}
+
+		dir := p.ModuleDir(d.Name())
+		if entry.Replace != "" && p.IsLocalReplacement(entry.Replace) {
+			target, err := filepath.EvalSymlinks(dir)
+			source, _ := filepath.EvalSymlinks(p.localPath(entry.Replace))
+			if err == nil && target == source {
+				return dir
+			}
+			continue
+		}
+
+		origin := p.git.RemoteURL(dir, "origin")
+		if origin != "" && p.options.Rewrites.Same(origin, replacedSource(entry)) {
+			return dir
+		}
+	}
+	return ""
+}
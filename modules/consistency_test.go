@@ -0,0 +1,94 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNameMismatches(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+
+	tests := []struct {
+		name string
+		// Drift the installed json module away from its lock entry.
+		drift   func(p *Project)
+		lockKey string
+		dir     string
+	}{
+		{
+			"lock key differs from the declared name",
+			func(p *Project) {
+				p.renameLockEntry("json", "legacy")
+				os.Rename(p.ModuleDir("json"), p.ModuleDir("legacy"))
+			},
+			"legacy", "legacy",
+		},
+		{
+			"directory differs from the lock key",
+			func(p *Project) { os.Rename(p.ModuleDir("json"), p.ModuleDir("moved")) },
+			"json", "moved",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProject(t, Options{})
+			if _, err := p.Install(repo, "v1.0.0"); err != nil {
+				t.Fatalf("Install: %v", err)
+			}
+			test.drift(p)
+
+			mismatches := p.FindNameMismatches()
+			if len(mismatches) != 1 {
+				t.Fatalf("FindNameMismatches = %v, want one mismatch", mismatches)
+			}
+			mismatch := mismatches[0]
+			if mismatch.LockKey != test.lockKey || mismatch.DeclaredName != "json" || mismatch.Dir != p.ModuleDir(test.dir) {
+				t.Errorf("FindNameMismatches = %+v", mismatch)
+			}
+
+			if err := p.ReconcileNameMismatch(mismatch); err != nil {
+				t.Fatalf("ReconcileNameMismatch: %v", err)
+			}
+			if got := installedVersion(t, p, "json"); got != "v1.0.0" {
+				t.Errorf("reconciled json is %s", got)
+			}
+			if _, locked := readLock(t, p)["json"]; !locked {
+				t.Errorf("reconciled lockfile has no json entry: %v", readLock(t, p))
+			}
+			if mismatches := p.FindNameMismatches(); len(mismatches) != 0 {
+				t.Errorf("FindNameMismatches after reconciling = %v", mismatches)
+			}
+		})
+	}
+}
+
+func TestRestoreUsesLockKeyDirectory(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	p := newTestProject(t, Options{})
+	if _, err := p.Install(repo, "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := p.renameLockEntry("json", "legacy"); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(p.PkgDir())
+
+	if _, err := p.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(p.ModuleDir("legacy"), "json.acid")); err != nil {
+		t.Errorf("json was not restored under its lock key: %v", err)
+	}
+	if _, err := os.Stat(p.ModuleDir("json")); err == nil {
+		t.Errorf("Restore created %s, which no lock entry names", p.ModuleDir("json"))
+	}
+	if _, err := p.Restore(); err != nil {
+		t.Errorf("a second Restore failed: %v", err)
+	}
+	if _, err := os.Stat(p.ModuleDir("json")); err == nil {
+		t.Errorf("a second Restore created %s", p.ModuleDir("json"))
+	}
+}
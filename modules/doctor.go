@@ -0,0 +1,205 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/lock"
+)
+
+// A problem found by ace doctor.
+//
+// Fix applies a safe repair, and is nil when the problem needs a human.
+type Issue struct {
+	Description string
+	Fix         func() error
+}
+
+// Cross-check the install directory, the lockfile and each module's
+// module.acidcfg, and report every inconsistency found.
+func (p *Project) Diagnose() []Issue {
+	var issues []Issue
+
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		lockFile = make(lock.LockFile)
+		if _, statErr := os.Stat(p.PkgDir()); statErr == nil {
+			issues = append(issues, Issue{Description: fmt.Sprintf("%s exists but %s is missing or unreadable", p.PkgDir(), p.lockName)})
+		}
+	}
+
+	issues = append(issues, p.staleCloneIssues()...)
+
+	mismatches := p.FindNameMismatches()
+	claimedDirs := make(map[string]bool)
+	claimedKeys := make(map[string]bool)
+	for _, mismatch := range mismatches {
+		claimedDirs[mismatch.Dir] = true
+		claimedKeys[mismatch.LockKey] = true
+		issues = append(issues, Issue{
+			Description: mismatch.String(),
+			Fix:         func() error { return p.ReconcileNameMismatch(mismatch) },
+		})
+	}
+
+	replacements := p.Replacements()
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		if claimedKeys[moduleName] {
+			continue
+		}
+		entry := lockFile[moduleName]
+		issues = append(issues, p.lockEntryIssues(moduleName, entry, replacements)...)
+	}
+
+	dirs, _ := os.ReadDir(p.PkgDir())
+	for _, d := range dirs {
+		dir := p.ModuleDir(d.Name())
+		if _, locked := lockFile[d.Name()]; locked || claimedDirs[dir] {
+			continue
+		}
+		issues = append(issues, p.orphanIssue(dir))
+	}
+
+	return issues
+}
+
+// Find tmp_* clones left behind by an interrupted install, restore or upgrade.
+func (p *Project) staleCloneIssues() []Issue {
+	matches, _ := filepath.Glob(p.resolve("tmp_*"))
+
+	var issues []Issue
+	for _, dir := range matches {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		issues = append(issues, Issue{
+			Description: fmt.Sprintf("stale clone %s left behind by an interrupted operation", dir),
+			Fix: func() error {
+				if err := os.RemoveAll(dir); err != nil {
+					return err
+				}
+				p.logf("Removed %s", dir)
+				return nil
+			},
+		})
+	}
+	return issues
+}
+
+func (p *Project) lockEntryIssues(moduleName string, entry lock.LockEntry, replacements map[string]string) []Issue {
+	dir := p.ModuleDir(moduleName)
+
+	if _, err := os.Lstat(dir); err != nil {
+		return []Issue{{
+			Description: fmt.Sprintf("lock entry '%s' has no directory %s", moduleName, dir),
+			Fix: func() error {
+				_, err := p.restoreModule(moduleName, entry, replacements)
+				return err
+			},
+		}}
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return []Issue{{
+			Description: fmt.Sprintf("%s is a broken link to %s", dir, entry.Replace),
+		}}
+	}
+
+	if _, err := ParseModuleConfig(filepath.Join(dir, "module.acidcfg")); err != nil {
+		return []Issue{{
+			Description: fmt.Sprintf("%s has a missing or invalid module.acidcfg: %v", dir, err),
+		}}
+	}
+
+	// Local replacements track a working copy, so their HEAD moves freely.
+	if entry.Replace != "" && p.IsLocalReplacement(entry.Replace) {
+		return nil
+	}
+
+	// Archives have no commit, only the checksum of what they extracted to.
+	if entry.Checksum != "" {
+		return p.archiveIssues(moduleName, dir, entry, replacements)
+	}
+
+	// Modules fetched from a proxy carry no git metadata to check, and git
+	// would report the project's own HEAD instead.
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return nil
+	}
+
+	currentHash := p.git.CommitHash(dir)
+	if entry.CommitHash == "" || currentHash == "" || currentHash == entry.CommitHash {
+		return nil
+	}
+
+	issue := Issue{
+		Description: fmt.Sprintf("%s is checked out at %s, but %s pins %s", dir, shortHash(currentHash), p.lockName, shortHash(entry.CommitHash)),
+	}
+	if clean, err := p.git.IsClean(dir); err == nil && clean {
+		issue.Fix = func() error {
+			if err := p.git.Checkout(dir, entry.CommitHash); err != nil {
+				if err := p.git.Fetch(dir, "origin", entry.CommitHash); err != nil {
+					return err
+				}
+				if err := p.git.Checkout(dir, entry.CommitHash); err != nil {
+					return err
+				}
+			}
+			p.logf("Checked out %s in %s", shortHash(entry.CommitHash), dir)
+			return nil
+		}
+	} else {
+		issue.Description += " and has local changes"
+	}
+	return []Issue{issue}
+}
+
+// Describe an installed directory that the lockfile does not know about,
+// adding it to the lock when it is a git clone of a module with a matching name.
+func (p *Project) orphanIssue(dir string) Issue {
+	issue := Issue{
+		Description: fmt.Sprintf("%s is not recorded in %s", dir, p.lockName),
+	}
+
+	config, err := ParseModuleConfig(filepath.Join(dir, "module.acidcfg"))
+	origin := p.git.RemoteURL(dir, "origin")
+	if err != nil || origin == "" || config.Name != filepath.Base(dir) {
+		issue.Description += ", re-install it or remove it"
+		return issue
+	}
+
+	issue.Fix = func() error {
+		err := p.updateLock(config.Name, p.entryFor(dir, origin))
+		if err == nil {
+			p.logf("Added %s to %s", config.Name, p.lockName)
+		}
+		return err
+	}
+	return issue
+}
+
+func (p *Project) archiveIssues(moduleName, dir string, entry lock.LockEntry, replacements map[string]string) []Issue {
+	sum, err := checksum.Dir(dir)
+	if err != nil || checksum.Equal(sum, entry.Checksum) {
+		return nil
+	}
+
+	return []Issue{{
+		Description: fmt.Sprintf("%s has changed since it was extracted, %s pins %s", dir, p.lockName, shortChecksum(entry.Checksum)),
+		// restoreModule swaps the new extraction in, so a failed download
+		// leaves the installed module as it was.
+		Fix: func() error {
+			_, err := p.restoreModule(moduleName, entry, replacements)
+			return err
+		},
+	}}
+}
+
+func shortHash(hash string) string {
+	if len(hash) >= 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,153 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/acidlang/ace/archive"
+)
+
+// Apply every fix Diagnose offers, failing on issues that need a human.
+func fixAll(t *testing.T, p *Project) {
+	t.Helper()
+	for _, issue := range p.Diagnose() {
+		if issue.Fix == nil {
+			t.Fatalf("%s: no fix offered", issue.Description)
+		}
+		if err := issue.Fix(); err != nil {
+			t.Fatalf("%s: fix failed: %v", issue.Description, err)
+		}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+	repo := newRepo(t, remotes, "json", "v1.0.0", "v1.1.0")
+	other := newRepo(t, remotes, "http", "v1.0.0")
+
+	tests := []struct {
+		name    string
+		drift   func(t *testing.T, p *Project)
+		problem string
+		fixable bool
+	}{
+		{
+			"missing directory",
+			func(t *testing.T, p *Project) { os.RemoveAll(p.ModuleDir("json")) },
+			"has no directory", true,
+		},
+		{
+			"commit drift",
+			func(t *testing.T, p *Project) { runGit(t, p.ModuleDir("json"), "checkout", "-q", "v1.1.0") },
+			"but acid.lock pins", true,
+		},
+		{
+			"commit drift with local changes",
+			func(t *testing.T, p *Project) {
+				runGit(t, p.ModuleDir("json"), "checkout", "-q", "v1.1.0")
+				os.WriteFile(filepath.Join(p.ModuleDir("json"), "json.acid"), []byte("// edited\n"), 0644)
+			},
+			"has local changes", false,
+		},
+		{
+			"stale clone",
+			func(t *testing.T, p *Project) { os.MkdirAll(p.resolve("tmp_json"), 0755) },
+			"stale clone", true,
+		},
+		{
+			"unlocked clone",
+			func(t *testing.T, p *Project) { runGit(t, p.PkgDir(), "clone", "-q", other, "http") },
+			"is not recorded in acid.lock", true,
+		},
+		{
+			"unlocked directory",
+			func(t *testing.T, p *Project) { os.MkdirAll(p.ModuleDir("notes"), 0755) },
+			"re-install it or remove it", false,
+		},
+		{
+			"invalid module.acidcfg",
+			func(t *testing.T, p *Project) {
+				os.WriteFile(filepath.Join(p.ModuleDir("json"), "module.acidcfg"), []byte("{"), 0644)
+			},
+			"invalid module.acidcfg", false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProject(t, Options{})
+			if _, err := p.Install(repo, "v1.0.0"); err != nil {
+				t.Fatalf("Install: %v", err)
+			}
+			if issues := p.Diagnose(); len(issues) != 0 {
+				t.Fatalf("Diagnose after install = %v", issues)
+			}
+			test.drift(t, p)
+
+			issues := p.Diagnose()
+			if len(issues) != 1 || !strings.Contains(issues[0].Description, test.problem) {
+				t.Fatalf("Diagnose = %v, want one issue mentioning %q", issues, test.problem)
+			}
+			if (issues[0].Fix != nil) != test.fixable {
+				t.Fatalf("%s: fixable %v, want %v", issues[0].Description, issues[0].Fix != nil, test.fixable)
+			}
+			if !test.fixable {
+				return
+			}
+
+			fixAll(t, p)
+			if issues := p.Diagnose(); len(issues) != 0 {
+				t.Errorf("Diagnose after fixing = %v", issues)
+			}
+			if got := installedVersion(t, p, "json"); got != "v1.0.0" {
+				t.Errorf("json is at %s after fixing, want the locked v1.0.0", got)
+			}
+		})
+	}
+}
+
+func TestDoctorArchive(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	zipPath := filepath.Join(t.TempDir(), "json.zip")
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.WriteZip(repo, file); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	p := newTestProject(t, Options{})
+	if _, err := p.Install("file://"+zipPath, ""); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	edited := filepath.Join(p.ModuleDir("json"), "json.acid")
+	os.WriteFile(edited, []byte("// edited\n"), 0644)
+
+	issues := p.Diagnose()
+	if len(issues) != 1 || !strings.Contains(issues[0].Description, "has changed since it was extracted") || issues[0].Fix == nil {
+		t.Fatalf("Diagnose = %v, want one fixable archive issue", issues)
+	}
+
+	// A failed download must leave the installed module alone.
+	os.Rename(zipPath, zipPath+".bak")
+	if err := issues[0].Fix(); err == nil {
+		t.Errorf("fix succeeded without the archive")
+	}
+	if content, err := os.ReadFile(edited); err != nil || string(content) != "// edited\n" {
+		t.Errorf("a failed fix changed the installed module: %q, %v", content, err)
+	}
+
+	os.Rename(zipPath+".bak", zipPath)
+	fixAll(t, p)
+	if got := installedVersion(t, p, "json"); got != "v1.0.0" {
+		t.Errorf("json is %s after fixing, want the archived v1.0.0", got)
+	}
+	if issues := p.Diagnose(); len(issues) != 0 {
+		t.Errorf("Diagnose after fixing = %v", issues)
+	}
+}
@@ -0,0 +1,120 @@
+package modules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/acidlang/ace/lock"
+)
+
+var (
+	// A module, lock entry or acid.lock itself does not exist.
+	ErrNotFound = errors.New("not found")
+	// A repository could not be cloned or fetched, or an archive downloaded
+	// and extracted.
+	ErrCloneFailed = errors.New("clone failed")
+	// A requested version or locked commit could not be checked out.
+	ErrCheckoutFailed = errors.New("checkout failed")
+	// A module.acidcfg, acid.lock or ace.work file is missing fields or malformed.
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// A module name is already taken by a module from another repository.
+	ErrConflict = errors.New("conflict")
+	// Downloaded content did not match its published checksum or commit,
+	// or acid.lock does not match the project in frozen mode.
+	ErrVerificationFailed = errors.New("verification failed")
+	// A check such as ace doctor or ace tidy --check found problems.
+	ErrCheckFailed = errors.New("check failed")
+	// A module's license is not accepted by the project's license policy.
+	ErrLicenseDenied = errors.New("license not allowed")
+	// A module needs a newer ace than the one running.
+	ErrIncompatible = errors.New("incompatible module")
+	// acid.lock would be written while frozen.
+	ErrFrozen = lock.ErrFrozen
+)
+
+// A problem in a module.acidcfg, at a 1-based line and column.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Locate a byte offset in a file's content for a ConfigError.
+func newConfigError(filename string, content []byte, offset int64, err error) *ConfigError {
+	offset = max(0, min(offset, int64(len(content))))
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return &ConfigError{File: filename, Line: line, Column: column, Err: err}
+}
+
+// An error affecting a single module.
+type ModuleError struct {
+	Module string
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Module, e.Err)
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// The failures of an operation over several modules, such as a restore.
+//
+// errors.Is matches against every failure, so a partly failed restore still
+// reports ErrCheckoutFailed when one of its modules could not be checked out.
+type OperationError struct {
+	Operation string
+	Total     int
+	Failures  []error
+}
+
+func (e *OperationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed for %d of %d module(s):", e.Operation, len(e.Failures), e.Total)
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&b, "\n  - %v", failure)
+	}
+	return b.String()
+}
+
+func (e *OperationError) Unwrap() []error {
+	return e.Failures
+}
+
+// Collects per-module failures during a multi-module operation.
+type failureSummary struct {
+	operation string
+	total     int
+	failures  []error
+}
+
+func (s *failureSummary) add(moduleName string, err error) {
+	s.total++
+	if err != nil {
+		s.failures = append(s.failures, &ModuleError{Module: moduleName, Err: err})
+	}
+}
+
+// Get the combined error, or nil if every module succeeded.
+func (s *failureSummary) err() error {
+	if len(s.failures) == 0 {
+		return nil
+	}
+	return &OperationError{Operation: s.operation, Total: s.total, Failures: s.failures}
+}
@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/acidlang/ace/lock"
+)
+
+// Check that acid.lock fully and exactly describes the project, as frozen
+// mode requires: every module pinned to a commit or, for archives, a content
+// checksum, replace directives in module.acidcfg matching the lock, and lock
+// keys matching declared names.
+//
+// Returns a description of every disagreement found.
+func (p *Project) CheckFrozen(lockFile lock.LockFile) []string {
+	var problems []string
+	replacements := p.Replacements()
+
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		entry := lockFile[moduleName]
+
+		if err := ValidateModuleName(moduleName); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		replacement := replacements[moduleName]
+		if replacement != entry.Replace {
+			switch {
+			case replacement == "":
+				problems = append(problems, fmt.Sprintf("acid.lock replaces %s with %s, but module.acidcfg does not", moduleName, entry.Replace))
+			case entry.Replace == "":
+				problems = append(problems, fmt.Sprintf("module.acidcfg replaces %s with %s, but acid.lock does not", moduleName, replacement))
+			default:
+				problems = append(problems, fmt.Sprintf("module.acidcfg replaces %s with %s, but acid.lock records %s", moduleName, replacement, entry.Replace))
+			}
+		}
+
+		if entry.CommitHash == "" && entry.Checksum == "" && !(replacement != "" && p.IsLocalReplacement(replacement)) {
+			problems = append(problems, fmt.Sprintf("%s is not pinned to a commit or checksum in acid.lock", moduleName))
+		}
+	}
+
+	var unlocked []string
+	for moduleName := range replacements {
+		if _, exists := lockFile[moduleName]; !exists {
+			unlocked = append(unlocked, moduleName)
+		}
+	}
+	sort.Strings(unlocked)
+	for _, moduleName := range unlocked {
+		problems = append(problems, fmt.Sprintf("module.acidcfg replaces %s, which is not in acid.lock", moduleName))
+	}
+
+	for _, mismatch := range p.FindNameMismatches() {
+		problems = append(problems, mismatch.String())
+	}
+
+	return problems
+}
+
+// Combine the problems found by CheckFrozen into a single error.
+func frozenError(problems []string) error {
+	return fmt.Errorf("%w: acid.lock does not match the project, refusing to continue in frozen mode:\n  - %s", ErrVerificationFailed, strings.Join(problems, "\n  - "))
+}
@@ -0,0 +1,161 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/acidlang/ace/lock"
+)
+
+func TestCheckFrozen(t *testing.T) {
+	pinned := lock.LockEntry{Repo: "https://example.com/acid/json", CommitHash: "0123456789abcdef"}
+
+	tests := []struct {
+		name     string
+		replace  string
+		lockFile lock.LockFile
+		problems []string
+	}{
+		{"exact", "", lock.LockFile{"json": pinned}, nil},
+		{"archive pinned by checksum", "", lock.LockFile{"json": {Repo: "https://example.com/json.zip", Checksum: "sha256:00"}}, nil},
+		{"unpinned", "", lock.LockFile{"json": {Repo: pinned.Repo}}, []string{"json is not pinned to a commit or checksum in acid.lock"}},
+		{"invalid lock key", "", lock.LockFile{"../json": pinned}, []string{"module name '../json'"}},
+		{
+			"replaced only in the lockfile", "",
+			lock.LockFile{"json": {Repo: pinned.Repo, CommitHash: pinned.CommitHash, Replace: "https://example.com/fork/json"}},
+			[]string{"acid.lock replaces json with https://example.com/fork/json, but module.acidcfg does not"},
+		},
+		{
+			"replaced only in module.acidcfg", `"json": "https://example.com/fork/json"`,
+			lock.LockFile{"json": pinned},
+			[]string{"module.acidcfg replaces json with https://example.com/fork/json, but acid.lock does not"},
+		},
+		{
+			"replaced differently", `"json": "https://example.com/fork/json"`,
+			lock.LockFile{"json": {Repo: pinned.Repo, CommitHash: pinned.CommitHash, Replace: "https://example.com/other/json"}},
+			[]string{"but acid.lock records https://example.com/other/json"},
+		},
+		{
+			"replacement not locked", `"yaml": "../yaml"`,
+			lock.LockFile{"json": pinned},
+			[]string{"module.acidcfg replaces yaml, which is not in acid.lock"},
+		},
+		{"local replacement needs no pin", `"json": "../json"`, lock.LockFile{"json": {Repo: pinned.Repo, Replace: "../json"}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProject(t, Options{})
+			writeTestFile(t, p.ManifestPath(), `{"name": "app", "version": "0.1.0", "replace": {`+test.replace+`}}`)
+
+			problems := p.CheckFrozen(test.lockFile)
+			if len(problems) != len(test.problems) {
+				t.Fatalf("CheckFrozen = %q, want %q", problems, test.problems)
+			}
+			for i, problem := range problems {
+				if !strings.Contains(problem, test.problems[i]) {
+					t.Errorf("CheckFrozen = %q, want %q", problems, test.problems)
+				}
+			}
+		})
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0", "v1.1.0")
+	p := newTestProject(t, Options{})
+	if _, err := p.Install(repo, "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	locked, err := os.ReadFile(p.LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		project func(t *testing.T) *Project
+	}{
+		{"option", func(t *testing.T) *Project { return NewProject(p.Root, Options{Frozen: true}) }},
+		{"ACE_FROZEN", func(t *testing.T) *Project {
+			t.Setenv("ACE_FROZEN", "1")
+			return NewProject(p.Root, Options{})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frozen := test.project(t)
+			if !frozen.Frozen() {
+				t.Fatalf("Frozen() = false")
+			}
+
+			if _, err := frozen.Install(repo, "v1.1.0"); !errors.Is(err, lock.ErrFrozen) {
+				t.Errorf("Install = %v, want lock.ErrFrozen", err)
+			}
+			if _, err := frozen.Upgrade(); !errors.Is(err, lock.ErrFrozen) {
+				t.Errorf("Upgrade = %v, want lock.ErrFrozen", err)
+			}
+			if err := frozen.UpgradeWorkspace(Workspace{Root: p.Root, Members: []string{p.Root}}); !errors.Is(err, lock.ErrFrozen) {
+				t.Errorf("UpgradeWorkspace = %v, want lock.ErrFrozen", err)
+			}
+			if err := frozen.Remove("json"); !errors.Is(err, lock.ErrFrozen) {
+				t.Errorf("Remove = %v, want lock.ErrFrozen", err)
+			}
+
+			// Restoring an exact lockfile needs no writes.
+			os.RemoveAll(frozen.PkgDir())
+			if _, err := frozen.Restore(); err != nil {
+				t.Errorf("Restore = %v", err)
+			}
+			if got := installedVersion(t, frozen, "json"); got != "v1.0.0" {
+				t.Errorf("Restore installed %s, want the locked v1.0.0", got)
+			}
+			if content, _ := os.ReadFile(p.LockPath()); !reflect.DeepEqual(content, locked) {
+				t.Errorf("frozen operations changed acid.lock:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestFrozenRestoreRefusesDrift(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	p := newTestProject(t, Options{})
+	if _, err := p.Install(repo, "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		drift func(entry *lock.LockEntry)
+		want  error
+	}{
+		{"unpinned entry", func(entry *lock.LockEntry) { entry.CommitHash = "" }, ErrVerificationFailed},
+		{"missing commit", func(entry *lock.LockEntry) { entry.CommitHash = strings.Repeat("0", 40) }, ErrCheckoutFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lockFile := readLock(t, p)
+			entry := lockFile["json"]
+			test.drift(&entry)
+			lockFile["json"] = entry
+			lockPath := filepath.Join(t.TempDir(), "acid.lock")
+			if err := lock.WriteLockFile(lockPath, lockFile); err != nil {
+				t.Fatal(err)
+			}
+
+			frozen := NewProject(p.Root, Options{Frozen: true, LockFile: lockPath})
+			os.RemoveAll(frozen.PkgDir())
+			if _, err := frozen.Restore(); !errors.Is(err, test.want) {
+				t.Errorf("Restore = %v, want %v", err, test.want)
+			}
+			if _, err := os.Stat(frozen.ModuleDir("json")); err == nil {
+				t.Errorf("Restore installed json at another commit in frozen mode")
+			}
+		})
+	}
+}
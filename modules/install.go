@@ -0,0 +1,235 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acidlang/ace/archive"
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/cmds"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/registry"
+	"github.com/acidlang/ace/source"
+)
+
+// Install a module and record it in the lockfile.
+//
+// The input is a repository URL or a short name resolved through the
+// configured registries, and targetVersion is an optional tag, branch or
+// commit. Replace directives from module.acidcfg are honored.
+func (p *Project) Install(input, targetVersion string) (Module, error) {
+	if p.Frozen() {
+		return Module{}, lock.ErrFrozen
+	}
+
+	var (
+		inputURL     = input
+		published    registry.Version
+		hasPublished bool
+	)
+	if registry.IsShortName(input) {
+		result, err := p.registry.Resolve(input)
+		if err != nil {
+			return Module{}, err
+		}
+
+		p.logf("Resolved %s to %s (registry %s)", input, result.Package.Repo, result.Registry)
+		inputURL = result.Package.Repo
+		if targetVersion != "" {
+			published, hasPublished = result.Package.FindVersion(targetVersion)
+		}
+	}
+
+	inputURL = source.Clean(inputURL)
+	if archive.IsArchive(inputURL) {
+		return p.installArchive(inputURL, targetVersion, published, hasPublished)
+	}
+
+	var (
+		repoName     = source.Name(inputURL)
+		cloneDir     = p.cloneDir(inputURL)
+		cloneURL     = inputURL
+		replacements = p.Replacements()
+	)
+
+	if replacement, ok := replacements[repoName]; ok {
+		if p.IsLocalReplacement(replacement) {
+			return p.installLocalReplacement(repoName, replacement, inputURL)
+		}
+		p.logf("Using replacement %s for %s", replacement, repoName)
+		cloneURL = replacement
+	}
+
+	p.logf("Cloning...")
+
+	commitHash, proxied, err := p.fetchModule(cloneURL, targetVersion, cloneDir, !p.requiresSignature(repoName))
+	if err != nil {
+		return Module{}, err
+	}
+
+	moduleFile := filepath.Join(cloneDir, "module.acidcfg")
+	if !cmds.FileExists(moduleFile) {
+		os.RemoveAll(cloneDir)
+		return Module{}, fmt.Errorf("%w: no module.acidcfg file found", ErrInvalidConfig)
+	}
+
+	config, err := ParseModuleConfig(moduleFile)
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, fmt.Errorf("%w: error parsing module config: %v", ErrInvalidConfig, err)
+	}
+
+	// The declared name may differ from the repository name, in which case a
+	// replace directive keyed by the declared name only becomes visible now.
+	if replacement, ok := replacements[config.Name]; ok && config.Name != repoName {
+		os.RemoveAll(cloneDir)
+		if p.IsLocalReplacement(replacement) {
+			return p.installLocalReplacement(config.Name, replacement, inputURL)
+		}
+
+		p.logf("Using replacement %s for %s", replacement, config.Name)
+		cloneURL = replacement
+		commitHash, proxied, err = p.fetchModule(cloneURL, targetVersion, cloneDir, !p.requiresSignature(config.Name))
+		if err != nil {
+			return Module{}, err
+		}
+	}
+
+	// Only the declared name shows whether a proxied module needed signing.
+	if proxied && p.requiresSignature(config.Name) {
+		os.RemoveAll(cloneDir)
+		proxied = false
+		commitHash, err = p.cloneModule(cloneURL, targetVersion, cloneDir)
+		if err != nil {
+			return Module{}, err
+		}
+	}
+
+	signer, err := p.verifySignature(config.Name, cloneDir, targetVersion)
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+
+	if err := p.checkLicense(config.Name, cloneDir); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+	if err := p.checkRequirements(config.Name, cloneDir, config); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+
+	if err := p.CheckNameClash(config.Name, inputURL); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+
+	p.DropRenamedModule(inputURL, config.Name)
+
+	targetDir := p.ModuleDir(config.Name)
+
+	if commitHash == "" {
+		commitHash = p.git.CommitHash(cloneDir)
+	}
+
+	if hasPublished && cloneURL == inputURL {
+		if err := p.verifyPublished(cloneDir, commitHash, published); err != nil {
+			os.RemoveAll(cloneDir)
+			return Module{}, err
+		}
+	}
+
+	// A proxied module has no git metadata, so only a tag it was asked for
+	// is known to point at it.
+	entry := lock.LockEntry{Repo: inputURL}
+	if !proxied {
+		entry = p.entryFor(cloneDir, inputURL)
+	} else if targetVersion != commitHash {
+		entry.Tags = []string{targetVersion}
+	}
+	entry.CommitHash = commitHash
+	entry.RequestedVersion = targetVersion
+	entry.Signer = signer
+	if cloneURL != inputURL {
+		entry.Replace = cloneURL
+	}
+
+	if err := p.verifySum(cloneURL, cloneDir, commitHash, sumVersions(entry)...); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+
+	if err := p.moveInto(cloneDir, targetDir); err != nil {
+		return Module{}, err
+	}
+
+	p.logf("Saved module to %s", targetDir)
+
+	if err := p.updateLock(config.Name, entry); err != nil {
+		return Module{}, fmt.Errorf("error updating lockfile: %w", err)
+	}
+	p.logf("Lockfile updated with version information.")
+	return p.module(config.Name, entry), nil
+}
+
+// Search the configured registries for modules whose name or description
+// contains the term, see registry.Client.Search.
+func (p *Project) Search(term string) ([]registry.Result, []error, error) {
+	return p.registry.Search(term)
+}
+
+// Clone a repository into cloneDir, checking out targetVersion when one is given.
+//
+// Returns the checked out commit hash for versioned clones, or "" for shallow HEAD clones.
+func (p *Project) cloneModule(repoURL, targetVersion, cloneDir string) (string, error) {
+	if err := p.git.Clone(repoURL, cloneDir, targetVersion == ""); err != nil {
+		os.RemoveAll(cloneDir)
+		return "", fmt.Errorf("%w: error cloning repository: %v", ErrCloneFailed, err)
+	}
+	if targetVersion == "" {
+		return "", nil
+	}
+
+	if err := p.git.Checkout(cloneDir, targetVersion); err != nil {
+		os.RemoveAll(cloneDir)
+		return "", fmt.Errorf("%w: could not checkout version '%s'", ErrCheckoutFailed, targetVersion)
+	}
+
+	commitHash := p.git.CommitHash(cloneDir)
+	if len(commitHash) >= 8 {
+		p.logf("Checked out version %s (commit: %s)", targetVersion, commitHash[:7])
+	}
+	return commitHash, nil
+}
+
+// Check a clone against the commit and checksum a registry published for its version.
+func (p *Project) verifyPublished(cloneDir, commitHash string, published registry.Version) error {
+	if published.Commit != "" && !strings.HasPrefix(commitHash, published.Commit) {
+		return fmt.Errorf("%w: version %s resolved to commit %s, but the registry lists %s", ErrVerificationFailed, published.Version, commitHash, published.Commit)
+	}
+
+	if published.Checksum != "" {
+		sum, err := checksum.Dir(cloneDir)
+		if err != nil {
+			return fmt.Errorf("could not checksum %s: %v", cloneDir, err)
+		}
+		if !checksum.Equal(sum, published.Checksum) {
+			return fmt.Errorf("%w: checksum mismatch for %s: got %s, registry lists %s", ErrVerificationFailed, published.Version, sum, published.Checksum)
+		}
+		p.logf("Verified checksum %s", sum)
+	}
+	return nil
+}
+
+func (p *Project) installLocalReplacement(moduleName, localPath, repoURL string) (Module, error) {
+	p.logf("Using local replacement %s for %s", localPath, moduleName)
+	module, err := p.InstallLocalReplacement(moduleName, localPath, repoURL)
+	if err != nil {
+		return Module{}, fmt.Errorf("error installing local replacement: %w", err)
+	}
+	p.logf("Lockfile updated with version information.")
+	return module, nil
+}
@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/acidlang/ace/license"
+)
+
+// The license of an installed module.
+type License struct {
+	Module string
+	// SPDX identifier or expression, "" when it could not be determined.
+	License string
+	// Where it was found: "module.acidcfg", a license file name, or "" when
+	// there was nothing to go by.
+	Source string
+}
+
+// Determine the license of the module sources in dir: the license field of
+// its module.acidcfg, or else the license file it ships.
+func detectLicense(dir string) (string, string) {
+	if config, err := ParseModuleConfig(filepath.Join(dir, "module.acidcfg")); err == nil && config.License != "" {
+		return config.License, "module.acidcfg"
+	}
+	return license.Detect(dir)
+}
+
+// List the license of every module in the lockfile, sorted by module name.
+func (p *Project) Licenses() ([]License, error) {
+	lockFile, err := p.readLock()
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []License
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		id, source := detectLicense(p.ModuleDir(moduleName))
+		licenses = append(licenses, License{Module: moduleName, License: id, Source: source})
+	}
+	return licenses, nil
+}
+
+// Get the license policy from Options.LicensePolicy.
+func (p *Project) LicensePolicy() license.Policy {
+	return p.options.LicensePolicy
+}
+
+// Refuse to add a module whose license the policy does not accept.
+func (p *Project) checkLicense(moduleName, dir string) error {
+	policy := p.LicensePolicy()
+	if !policy.Enabled() {
+		return nil
+	}
+
+	id, _ := detectLicense(dir)
+	if policy.Allows(id) {
+		return nil
+	}
+	if id != "" && !license.Valid(id) {
+		return fmt.Errorf("%w: %s declares license '%s', which is not a valid SPDX expression", ErrLicenseDenied, moduleName, id)
+	}
+	if id == "" {
+		return fmt.Errorf("%w: the license of %s could not be determined, and the policy only allows known licenses", ErrLicenseDenied, moduleName)
+	}
+	return fmt.Errorf("%w: %s is licensed under %s, which the license policy does not allow", ErrLicenseDenied, moduleName, id)
+}
@@ -1,288 +1,641 @@
 package modules
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 
-	"github.com/acidlang/ace/cmds"
-	"github.com/acidlang/ace/git"
+	"github.com/acidlang/ace/archive"
 	"github.com/acidlang/ace/lock"
-	"github.com/acidlang/ace/texts"
+	"github.com/acidlang/ace/semver"
+	"github.com/acidlang/ace/source"
 )
 
-// A module configuration, containing name, author and version.
+// A module configuration, containing name, author and version. Name and
+// version are required.
+//
+// Replace maps a dependency's module name to a local path or another repository URL.
+// PkgDir and LockFile relocate the project's install directory and lockfile,
+// relative to the project root. Signers maps a dependency's module name to the
+// keys allowed to sign its tags or commits. License is an SPDX identifier or
+// expression.
+//
+// Acid and Ace are the minimum Acid language and ace versions the module
+// needs, and Entry is the file its code is loaded from, relative to the
+// module root.
 type ModuleConfig struct {
-	Name    string `json:"name"`
-	Author  string `json:"author"`
-	Version string `json:"version"`
+	Name        string              `json:"name"`
+	Author      string              `json:"author"`
+	Version     string              `json:"version"`
+	Description string              `json:"description,omitempty"`
+	License     string              `json:"license,omitempty"`
+	Repository  string              `json:"repository,omitempty"`
+	Homepage    string              `json:"homepage,omitempty"`
+	Keywords    []string            `json:"keywords,omitempty"`
+	Acid        string              `json:"acid,omitempty"`
+	Ace         string              `json:"ace,omitempty"`
+	Entry       string              `json:"entry,omitempty"`
+	PkgDir      string              `json:"pkg_dir,omitempty"`
+	LockFile    string              `json:"lockfile,omitempty"`
+	Replace     map[string]string   `json:"replace,omitempty"`
+	Signers     map[string][]string `json:"signers,omitempty"`
 }
 
 // Parse the module configuration from a file and get back the object.
+//
+// Errors locate the problem in the file as a *ConfigError. Unknown fields are
+// ignored, see ParseModuleConfigWithWarnings.
 func ParseModuleConfig(filename string) (ModuleConfig, error) {
+	config, _, err := ParseModuleConfigWithWarnings(filename)
+	return config, err
+}
+
+// The fields a module configuration must have.
+var requiredFields = []string{"name", "version"}
+
+// Parse the module configuration from a file like ParseModuleConfig, and also
+// get back a *ConfigError for each field ace does not know, which is usually
+// a misspelt one.
+func ParseModuleConfigWithWarnings(filename string) (ModuleConfig, []error, error) {
 	var config ModuleConfig
 
 	file, err := os.Open(filename)
 	if err != nil {
-		return config, err
+		return config, nil, err
 	}
 	defer file.Close()
 
 	content, err := io.ReadAll(file)
 	if err != nil {
-		return config, err
-	}
-
-	lines := strings.SplitSeq(string(content), "\n")
-	for line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "\"name\":") {
-			config.Name = texts.ExtractString(line, "\"name\": \"", "\"")
-		} else if strings.Contains(line, "\"author\":") {
-			config.Author = texts.ExtractString(line, "\"author\": \"", "\"")
-		} else if strings.Contains(line, "\"version\":") {
-			config.Version = texts.ExtractString(line, "\"version\": \"", "\"")
-		}
+		return config, nil, err
 	}
 
-	return config, nil
-}
-
-// Write the module configuration to disk.
-func WriteModuleConfig(filename string, config ModuleConfig) error {
-	file, err := os.Create(filename)
+	fields := map[string]any{
+		"name":        &config.Name,
+		"author":      &config.Author,
+		"version":     &config.Version,
+		"description": &config.Description,
+		"license":     &config.License,
+		"repository":  &config.Repository,
+		"homepage":    &config.Homepage,
+		"keywords":    &config.Keywords,
+		"acid":        &config.Acid,
+		"ace":         &config.Ace,
+		"entry":       &config.Entry,
+		"pkg_dir":     &config.PkgDir,
+		"lockfile":    &config.LockFile,
+		"replace":     &config.Replace,
+		"signers":     &config.Signers,
+	}
+	decoded, warnings, err := decodeObject(filename, content, fields)
 	if err != nil {
-		return err
+		return config, warnings, err
 	}
-	defer file.Close()
 
-	file.WriteString("{\n")
-	fmt.Fprintf(file, "  \"name\": \"%s\",\n", config.Name)
-	fmt.Fprintf(file, "  \"author\": \"%s\",\n", config.Author)
-	fmt.Fprintf(file, "  \"version\": \"%s\"\n", config.Version)
-	file.WriteString("}\n")
+	at := func(field string, err error) error {
+		return newConfigError(filename, content, decoded[field], err)
+	}
 
-	return nil
+	for _, field := range requiredFields {
+		if _, ok := decoded[field]; !ok {
+			return config, warnings, newConfigError(filename, content, decoded["{"], fmt.Errorf("missing required field \"%s\"", field))
+		}
+	}
+	if config.Version == "" {
+		return config, warnings, at("version", fmt.Errorf("version is empty"))
+	}
+
+	// The name becomes a directory under pkg/, so reject anything that could escape it.
+	if err := ValidateModuleName(config.Name); err != nil {
+		return config, warnings, at("name", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Replace)) {
+		if err := ValidateModuleName(name); err != nil {
+			return config, warnings, at("replace", fmt.Errorf("invalid replace directive: %v", err))
+		}
+		if config.Replace[name] == "" {
+			return config, warnings, at("replace", fmt.Errorf("replace directive for %s is empty", name))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Signers)) {
+		if err := ValidateModuleName(name); err != nil {
+			return config, warnings, at("signers", fmt.Errorf("invalid signers entry: %v", err))
+		}
+		if len(config.Signers[name]) == 0 {
+			return config, warnings, at("signers", fmt.Errorf("signers entry for %s lists no keys", name))
+		}
+	}
+	if field, err := validateMetadata(config); err != nil {
+		return config, warnings, at(field, err)
+	}
+
+	return config, warnings, nil
 }
 
-func InitModuleFile() {
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Println("Error getting current directory")
-		os.Exit(1)
+// Decode a JSON object into the fields it is known to have, by name.
+//
+// Returns the offset of each decoded field's value, and of the object itself
+// under "{", along with a warning for each unknown or repeated field.
+func decodeObject(filename string, content []byte, fields map[string]any) (map[string]int64, []error, error) {
+	var warnings []error
+	offsets := make(map[string]int64)
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	syntaxError := func(err error) error {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			offset := syntax.Offset - 1
+			if offset >= 0 && offset < int64(len(content)) && content[offset] == ',' {
+				if next := valueOffset(content, offset); next < int64(len(content)) && (content[next] == '}' || content[next] == ']') {
+					return newConfigError(filename, content, offset, fmt.Errorf("invalid JSON: trailing comma"))
+				}
+			}
+			return newConfigError(filename, content, offset, fmt.Errorf("invalid JSON: %v", err))
+		}
+		return newConfigError(filename, content, int64(len(content)), fmt.Errorf("invalid JSON: unexpected end of file"))
 	}
 
-	projName := strings.ToLower(strings.ReplaceAll(filepath.Base(cwd), " ", "_"))
-	author := os.Getenv("USER")
-	if author == "" {
-		author = os.Getenv("USERNAME")
+	offsets["{"] = valueOffset(content, decoder.InputOffset())
+	token, err := decoder.Token()
+	if err != nil {
+		return offsets, warnings, syntaxError(err)
 	}
-	if author == "" {
-		author = "unknown"
+	if token != json.Delim('{') {
+		return offsets, warnings, newConfigError(filename, content, offsets["{"], fmt.Errorf("expected a JSON object"))
 	}
 
-	config := ModuleConfig{
-		Name:    projName,
-		Author:  author,
-		Version: "0.1.0",
-	}
+	for decoder.More() {
+		keyOffset := valueOffset(content, decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return offsets, warnings, syntaxError(err)
+		}
+		key := token.(string)
+		offset := valueOffset(content, decoder.InputOffset())
+
+		target, known := fields[key]
+		if !known {
+			warnings = append(warnings, newConfigError(filename, content, keyOffset, fmt.Errorf("unknown field \"%s\"", key)))
+			target = new(json.RawMessage)
+		} else if _, seen := offsets[key]; seen {
+			warnings = append(warnings, newConfigError(filename, content, keyOffset, fmt.Errorf("field \"%s\" is repeated, the last one is used", key)))
+		}
 
-	if _, err := os.Stat("module.acidcfg"); err == nil {
-		fmt.Println("module.acidcfg already exists. Aborting.")
-		os.Exit(1)
+		if err := decoder.Decode(target); err != nil {
+			var typeError *json.UnmarshalTypeError
+			if errors.As(err, &typeError) {
+				return offsets, warnings, newConfigError(filename, content, offset, fmt.Errorf("field \"%s\" must be %s", key, jsonType(reflect.TypeOf(target).Elem(), false)))
+			}
+			return offsets, warnings, syntaxError(err)
+		}
+		if known {
+			offsets[key] = offset
+		}
 	}
 
-	err = WriteModuleConfig("module.acidcfg", config)
-	if err != nil {
-		fmt.Printf("Error writing module.acidcfg: %v\n", err)
-		os.Exit(1)
+	if _, err := decoder.Token(); err != nil {
+		return offsets, warnings, syntaxError(err)
+	}
+	offset := valueOffset(content, decoder.InputOffset())
+	if _, err := decoder.Token(); err != io.EOF {
+		return offsets, warnings, newConfigError(filename, content, offset, fmt.Errorf("unexpected content after the closing brace"))
 	}
+	return offsets, warnings, nil
+}
 
-	fmt.Println("Initialized module.")
+// Skip from offset past whitespace and separators to the next JSON value.
+func valueOffset(content []byte, offset int64) int64 {
+	for offset < int64(len(content)) && strings.IndexByte(" \t\r\n:,", content[offset]) != -1 {
+		offset++
+	}
+	return offset
 }
 
-func ListModules() {
-	lockFile, err := lock.ParseLockFile("acid.lock")
-	if err != nil {
-		fmt.Println("No acid.lock file found.")
-		os.Exit(1)
+// Describe a Go type as the JSON value it decodes from, e.g. "an array of
+// strings".
+func jsonType(t reflect.Type, plural bool) string {
+	var name string
+	switch t.Kind() {
+	case reflect.String:
+		name = "string"
+	case reflect.Slice:
+		name = "array of " + jsonType(t.Elem(), true)
+	case reflect.Map:
+		name = "object of " + jsonType(t.Elem(), true)
+	default:
+		name = t.String()
 	}
 
-	if len(lockFile) == 0 {
-		fmt.Println("No modules installed.")
-		return
+	switch {
+	case plural && t.Kind() == reflect.Slice:
+		return "arrays" + strings.TrimPrefix(name, "array")
+	case plural && t.Kind() == reflect.Map:
+		return "objects" + strings.TrimPrefix(name, "object")
+	case plural:
+		return name + "s"
+	case t.Kind() == reflect.String:
+		return "a " + name
 	}
+	return "an " + name
+}
 
-	for moduleName, entry := range lockFile {
-		versionInfo := ""
-		if entry.RequestedVersion != "" {
-			versionInfo = fmt.Sprintf(" (v%s)", entry.RequestedVersion)
-		} else if entry.CommitHash != "" && len(entry.CommitHash) >= 7 {
-			versionInfo = fmt.Sprintf(" (%s)", entry.CommitHash[:7])
+// Check the optional descriptive fields and requirements of a module
+// configuration, returning the field at fault with the error.
+func validateMetadata(config ModuleConfig) (string, error) {
+	if config.Acid != "" && !semver.Valid(config.Acid) {
+		return "acid", fmt.Errorf("acid '%s' is not a semantic version such as 0.3.0", config.Acid)
+	}
+	if config.Ace != "" && !semver.Valid(config.Ace) {
+		return "ace", fmt.Errorf("ace '%s' is not a semantic version such as 0.1.0", config.Ace)
+	}
+	if config.Repository != "" && !source.IsRemote(config.Repository) {
+		return "repository", fmt.Errorf("repository '%s' is not a remote repository URL", config.Repository)
+	}
+	if config.Homepage != "" {
+		if u, err := url.Parse(config.Homepage); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "homepage", fmt.Errorf("homepage '%s' is not an http(s) URL", config.Homepage)
+		}
+	}
+	if config.Entry != "" {
+		entry := filepath.ToSlash(config.Entry)
+		if path.IsAbs(entry) || filepath.IsAbs(config.Entry) || entry != path.Clean(entry) || entry == "." || strings.HasPrefix(entry, "../") || entry == ".." {
+			return "entry", fmt.Errorf("entry '%s' must be a relative path inside the module, such as src/main.acid", config.Entry)
 		}
-
-		fmt.Printf("- %s%s @ %s (installed %s)\n", moduleName, versionInfo, entry.Repo, entry.Timestamp)
 	}
+	for _, keyword := range config.Keywords {
+		if strings.TrimSpace(keyword) == "" {
+			return "keywords", fmt.Errorf("keywords must not be empty")
+		}
+	}
+	return "", nil
 }
 
-func ShowModuleInfo(moduleName string) {
-	lockFile, err := lock.ParseLockFile("acid.lock")
-	if err != nil {
-		fmt.Println("No acid.lock file found.")
-		os.Exit(1)
+// Check that a module name is safe to use as a directory under pkg/.
+//
+// Names are 1-64 characters of letters, digits, '_', '-' and '.', starting
+// with a letter or digit, so they can never contain separators or "..".
+func ValidateModuleName(name string) error {
+	if name == "" {
+		return fmt.Errorf("module name is empty")
 	}
-
-	entry, exists := lockFile[moduleName]
-	if !exists {
-		fmt.Printf("Module '%s' not found in lock file.\n", moduleName)
-		os.Exit(1)
+	if len(name) > 64 {
+		return fmt.Errorf("module name '%s' is longer than 64 characters", name)
 	}
 
-	fmt.Printf("Module: %s\n", moduleName)
-	fmt.Printf("Repository: %s\n", entry.Repo)
-	fmt.Printf("Installed At: %s\n", entry.Timestamp)
+	for i, r := range name {
+		isAlnum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if i == 0 && !isAlnum {
+			return fmt.Errorf("module name '%s' must start with a letter or digit", name)
+		}
+		if !isAlnum && r != '_' && r != '-' && r != '.' {
+			return fmt.Errorf("module name '%s' contains invalid character %q", name, r)
+		}
+	}
+	return nil
+}
 
-	if entry.RequestedVersion != "" {
-		fmt.Printf("Requested Version: %s\n", entry.RequestedVersion)
+// Make a valid module name out of an arbitrary string, e.g. a directory name.
+func sanitizeModuleName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		isAlnum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if isAlnum || (b.Len() > 0 && (r == '_' || r == '-' || r == '.')) {
+			b.WriteRune(r)
+		} else if b.Len() > 0 {
+			b.WriteRune('_')
+		}
 	}
 
-	if entry.CommitHash != "" {
-		fmt.Printf("Commit Hash: %s\n", entry.CommitHash)
+	sanitized := b.String()
+	if len(sanitized) > 64 {
+		sanitized = sanitized[:64]
+	}
+	sanitized = strings.TrimRight(sanitized, "_-.")
+	if sanitized == "" {
+		return "module"
 	}
+	return sanitized
+}
 
-	if entry.Branch != "" {
-		fmt.Printf("Branch: %s\n", entry.Branch)
+// Write the module configuration to disk, as indented JSON with its fields
+// in a fixed order and map entries sorted, so rewriting an unchanged
+// configuration leaves the file as it was.
+func WriteModuleConfig(filename string, config ModuleConfig) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
+		return err
 	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
 
-	if len(entry.Tags) > 0 {
-		fmt.Printf("Tags: %s\n", strings.Join(entry.Tags, ", "))
+// Check that installing repoURL as moduleName would not take over a module
+// that was installed from a different repository.
+func (p *Project) CheckNameClash(moduleName, repoURL string) error {
+	if entry, exists := p.lockFile()[moduleName]; exists {
+		if !p.options.Rewrites.Same(entry.Repo, repoURL) {
+			return fmt.Errorf("%w: module name '%s' is already used by %s, remove it first with 'ace -r=%s'", ErrConflict, moduleName, entry.Repo, moduleName)
+		}
+		return nil
 	}
 
-	moduleCfg := filepath.Join("pkg", moduleName, "module.acidcfg")
-	if _, err := os.Stat(moduleCfg); err == nil {
-		config, err := ParseModuleConfig(moduleCfg)
-		if err == nil {
-			if config.Author != "" {
-				fmt.Printf("Author: %s\n", config.Author)
-			}
-			if config.Version != "" {
-				fmt.Printf("Module Version: %s\n", config.Version)
-			}
+	targetDir := p.ModuleDir(moduleName)
+	if _, err := os.Lstat(targetDir); err == nil {
+		origin := p.git.RemoteURL(targetDir, "origin")
+		if origin != "" && !p.options.Rewrites.Same(origin, repoURL) {
+			return fmt.Errorf("%w: %s already holds a module from %s that is not in %s", ErrConflict, targetDir, origin, p.lockName)
 		}
-	} else {
-		fmt.Println("Warning: module.acidcfg not found in pkg/ directory")
 	}
+	return nil
 }
 
-func DeleteModule(moduleName string) {
-	lockFile, err := lock.ParseLockFile("acid.lock")
+// Remove an installed module and its lock entry.
+func (p *Project) Remove(moduleName string) error {
+	lockFile := p.lockFile()
 	found := false
+	inLock := false
 	targetDir := ""
+	lockKey := moduleName
 
-	if err == nil {
-		if _, exists := lockFile[moduleName]; exists {
-			found = true
-		}
+	if _, exists := lockFile[moduleName]; exists {
+		found = true
+		inLock = true
 	}
 
-	pkgDir := "pkg"
-	if _, err := os.Stat(pkgDir); err == nil {
-		entries, err := os.ReadDir(pkgDir)
-		if err == nil {
-			for _, entry := range entries {
-				if entry.IsDir() && entry.Name() == moduleName {
-					targetDir = filepath.Join(pkgDir, entry.Name())
-					found = true
-					break
-				}
+	pkgDir := p.PkgDir()
+	if entries, err := os.ReadDir(pkgDir); err == nil {
+		for _, entry := range entries {
+			isDir := entry.IsDir() || entry.Type()&os.ModeSymlink != 0
+			if isDir && entry.Name() == moduleName {
+				targetDir = filepath.Join(pkgDir, entry.Name())
+				found = true
+				break
 			}
 		}
 	}
 
+	// A module renamed upstream may live under a different directory or lock key.
+	for _, mismatch := range p.FindNameMismatches() {
+		if mismatch.LockKey == moduleName && targetDir == "" {
+			targetDir = mismatch.Dir
+			found = true
+		} else if mismatch.DeclaredName == moduleName && mismatch.Dir == targetDir {
+			lockKey = mismatch.LockKey
+			found = true
+			inLock = true
+		}
+	}
+
 	if !found {
-		fmt.Printf("Module %s not found.\n", moduleName)
-		os.Exit(1)
+		return fmt.Errorf("%w: module %s", ErrNotFound, moduleName)
+	}
+	if inLock && p.Frozen() {
+		return lock.ErrFrozen
 	}
 
 	if targetDir != "" {
 		if err := os.RemoveAll(targetDir); err != nil {
-			fmt.Printf("Error removing directory %s: %v\n", targetDir, err)
-		} else {
-			fmt.Printf("Removed module directory %s\n", targetDir)
+			return fmt.Errorf("error removing directory %s: %v", targetDir, err)
 		}
+		p.logf("Removed module directory %s", targetDir)
+	}
+
+	if !inLock {
+		p.logf("Module %s not found in lock file.", moduleName)
+		return nil
 	}
+	return p.removeFromLock(lockKey)
+}
 
-	lock.RemoveFromLockFile(moduleName)
+// The outcome of upgrading a single module.
+type Upgrade struct {
+	Module
+	// The lock key and commit before the upgrade. OldName differs from Name
+	// when the module was renamed upstream.
+	OldName   string
+	OldCommit string
 }
 
-func UpgradeAllModules() {
-	lockFile, err := lock.ParseLockFile("acid.lock")
+// Check whether the upgrade moved the module to another commit or name.
+func (u Upgrade) Changed() bool {
+	return u.OldName != u.Name || u.OldCommit != u.CommitHash
+}
+
+// Upgrade every module in the lockfile to the latest commit of its source.
+//
+// Returns the modules that were upgraded or already up to date, and an
+// *OperationError listing the rest.
+func (p *Project) Upgrade() ([]Upgrade, error) {
+	lockFile, err := p.readLock()
 	if err != nil {
-		fmt.Println("No acid.lock found.")
-		os.Exit(1)
+		return nil, err
+	}
+	if p.Frozen() {
+		return nil, lock.ErrFrozen
 	}
 
 	if len(lockFile) == 0 {
-		fmt.Println("No modules to upgrade.")
-		return
+		p.logf("No modules to upgrade.")
+		return nil, nil
 	}
 
-	fmt.Println("Upgrading all modules to latest versions...")
+	p.logf("Upgrading all modules to latest versions...")
 
-	for moduleName, entry := range lockFile {
-		repoURL := entry.Repo
-		currentHash := entry.CommitHash
+	replacements := p.Replacements()
+	summary := failureSummary{operation: "upgrade"}
 
-		fmt.Printf("Checking %s...\n", moduleName)
+	var upgrades []Upgrade
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		upgrade, err := p.upgradeModule(moduleName, lockFile[moduleName], replacements)
+		if err != nil {
+			p.logf("  Error: %v", err)
+		} else {
+			upgrades = append(upgrades, upgrade)
+		}
+		summary.add(moduleName, err)
+	}
+	return upgrades, summary.err()
+}
 
-		latestHash := git.GetLatestCommitHash(repoURL)
+// Upgrade a single lock entry to the latest commit of its source, honoring
+// the given replace directives.
+func (p *Project) upgradeModule(moduleName string, entry lock.LockEntry, replacements map[string]string) (Upgrade, error) {
+	upgrade := Upgrade{OldName: moduleName, OldCommit: entry.CommitHash}
+	if err := ValidateModuleName(moduleName); err != nil {
+		return upgrade, fmt.Errorf("%w: refusing to upgrade lock entry: %v", ErrInvalidConfig, err)
+	}
 
-		if latestHash != "" && latestHash != currentHash {
-			if len(currentHash) >= 7 && len(latestHash) >= 7 {
-				fmt.Printf("  Updating from %s to %s\n", currentHash[:7], latestHash[:7])
-			}
+	repoURL := entry.Repo
+	currentHash := entry.CommitHash
+	sourceURL := repoURL
+	targetDir := p.ModuleDir(moduleName)
 
-			parts := strings.Split(repoURL, "/")
-			repoName := strings.TrimSuffix(parts[len(parts)-1], ".git")
-			cloneDir := "tmp_" + repoName
+	p.logf("Checking %s...", moduleName)
 
-			err := cmds.RunCommand(fmt.Sprintf("git clone --depth 1 %s %s", repoURL, cloneDir))
+	if replacement, ok := replacements[moduleName]; ok {
+		if p.IsLocalReplacement(replacement) {
+			p.logf("  Replaced by local path %s, relinking", replacement)
+			module, err := p.InstallLocalReplacement(moduleName, replacement, repoURL)
 			if err != nil {
-				fmt.Printf("  Error cloning %s: %v\n", repoURL, err)
-				continue
+				return upgrade, fmt.Errorf("error linking %s: %w", replacement, err)
 			}
+			upgrade.Module = module
+			return upgrade, nil
+		}
+		p.logf("  Replaced by %s", replacement)
+		sourceURL = replacement
+	}
 
-			moduleFile := filepath.Join(cloneDir, "module.acidcfg")
-			if _, err := os.Stat(moduleFile); err != nil {
-				fmt.Printf("  Warning: No module.acidcfg found, skipping %s\n", moduleName)
-				os.RemoveAll(cloneDir)
-				continue
-			}
+	if archive.IsArchive(sourceURL) {
+		p.logf("  %s is pinned by its archive URL, install a newer archive to upgrade it", moduleName)
+		upgrade.Module = p.module(moduleName, entry)
+		return upgrade, nil
+	}
 
-			targetDir := filepath.Join("pkg", moduleName)
-			if _, err := os.Stat(targetDir); err == nil {
-				os.RemoveAll(targetDir)
-			}
+	latestHash := p.git.LatestCommitHash(sourceURL)
+	if latestHash == "" {
+		return upgrade, fmt.Errorf("%w: could not query latest commit of %s", ErrCloneFailed, sourceURL)
+	}
 
-			os.MkdirAll(filepath.Dir(targetDir), 0755)
-			err = os.Rename(cloneDir, targetDir)
-			if err != nil {
-				fmt.Printf("  Error moving %s to %s: %v\n", cloneDir, targetDir, err)
-				continue
-			}
+	if latestHash == currentHash && sourceURL == replacedSource(entry) {
+		p.logf("  %s is already up to date", moduleName)
+		upgrade.Module = p.module(moduleName, entry)
+		return upgrade, nil
+	}
 
-			cwd, _ := os.Getwd()
-			os.Chdir(targetDir)
-			newCommitHash := git.GetGitCommitHash(".")
-			newTags := git.GetGitTags(".")
-			newBranch := git.GetGitCurrentBranch(".")
-			os.Chdir(cwd)
-			lock.UpdateLockFile(moduleName, repoURL, newCommitHash, "", newTags, newBranch)
-			fmt.Printf("  Updated %s\n", moduleName)
-		} else {
-			fmt.Printf("  %s is already up to date\n", moduleName)
+	if len(currentHash) >= 7 && len(latestHash) >= 7 {
+		p.logf("  Updating from %s to %s", currentHash[:7], latestHash[:7])
+	}
+
+	cloneDir := p.cloneDir(sourceURL)
+	if err := p.git.Clone(sourceURL, cloneDir, true); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, fmt.Errorf("%w: error cloning %s: %v", ErrCloneFailed, sourceURL, err)
+	}
+
+	moduleFile := filepath.Join(cloneDir, "module.acidcfg")
+	if _, err := os.Stat(moduleFile); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, fmt.Errorf("%w: no module.acidcfg found in %s", ErrInvalidConfig, sourceURL)
+	}
+
+	config, err := ParseModuleConfig(moduleFile)
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, fmt.Errorf("%w: error parsing module config: %v", ErrInvalidConfig, err)
+	}
+
+	if err := p.verifySum(sourceURL, cloneDir, p.git.CommitHash(cloneDir)); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, err
+	}
+	signer, err := p.verifySignature(moduleName, cloneDir, "")
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, err
+	}
+	if err := p.checkLicense(moduleName, cloneDir); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, err
+	}
+	if err := p.checkRequirements(moduleName, cloneDir, config); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, err
+	}
+
+	// The repository is the module's identity, so follow an upstream rename.
+	newName := moduleName
+	if config.Name != moduleName {
+		if err := p.CheckNameClash(config.Name, repoURL); err != nil {
+			os.RemoveAll(cloneDir)
+			return upgrade, fmt.Errorf("%s was renamed to %s upstream: %w", moduleName, config.Name, err)
+		}
+		p.logf("  %s was renamed to %s upstream", moduleName, config.Name)
+		newName = config.Name
+	}
+
+	oldDir := targetDir
+	targetDir = p.ModuleDir(newName)
+	if err := p.moveInto(cloneDir, targetDir); err != nil {
+		os.RemoveAll(cloneDir)
+		return upgrade, err
+	}
+	// The old directory goes only once the renamed module is in place.
+	if newName != moduleName {
+		os.RemoveAll(oldDir)
+	}
+
+	newEntry := p.entryFor(targetDir, repoURL)
+	newEntry.Signer = signer
+	if !p.options.Rewrites.Same(sourceURL, repoURL) {
+		newEntry.Replace = sourceURL
+	}
+	if err := p.updateLock(newName, newEntry); err != nil {
+		return upgrade, fmt.Errorf("error updating lockfile: %w", err)
+	}
+	if newName != moduleName {
+		if err := p.removeFromLock(moduleName); err != nil {
+			return upgrade, fmt.Errorf("error removing %s from lockfile: %w", moduleName, err)
 		}
 	}
+	p.logf("  Updated %s", newName)
+
+	upgrade.Module = p.module(newName, newEntry)
+	return upgrade, nil
+}
+
+// Get the temporary directory a repository is cloned into before it is
+// moved under the install directory.
+func (p *Project) cloneDir(repoURL string) string {
+	return p.resolve("tmp_" + source.Name(repoURL))
+}
+
+// Move a finished clone to targetDir, replacing whatever is there.
+//
+// The previous contents are set aside first and put back if the move fails,
+// so a failed move never leaves the module missing.
+func (p *Project) moveInto(cloneDir, targetDir string) error {
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(targetDir), err)
+	}
+
+	backup := ""
+	if _, err := os.Lstat(targetDir); err == nil {
+		backup = filepath.Join(filepath.Dir(targetDir), ".old_"+filepath.Base(targetDir))
+		os.RemoveAll(backup)
+		if err := os.Rename(targetDir, backup); err != nil {
+			return fmt.Errorf("error moving %s aside: %v", targetDir, err)
+		}
+	}
+
+	if err := os.Rename(cloneDir, targetDir); err != nil {
+		if backup != "" {
+			os.Rename(backup, targetDir)
+		}
+		return fmt.Errorf("error moving %s to %s: %v", cloneDir, targetDir, err)
+	}
+	if backup != "" {
+		os.RemoveAll(backup)
+	}
+	return nil
+}
+
+// Get the URL a lock entry was actually fetched from.
+func replacedSource(entry lock.LockEntry) string {
+	if entry.Replace != "" {
+		return entry.Replace
+	}
+	return entry.Repo
 }
@@ -0,0 +1,164 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateModuleName(t *testing.T) {
+	valid := []string{"json", "JSON2", "http-client", "a_b.c", "0x", strings.Repeat("a", 64)}
+	invalid := []string{
+		"",
+		strings.Repeat("a", 65),
+		".",
+		"..",
+		".hidden",
+		"-flag",
+		"_private",
+		"a/b",
+		`a\b`,
+		"../json",
+		"json name",
+		"naïve",
+		"a:b",
+	}
+	for _, name := range valid {
+		if err := ValidateModuleName(name); err != nil {
+			t.Errorf("ValidateModuleName(%q) = %v, want valid", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if err := ValidateModuleName(name); err == nil {
+			t.Errorf("ValidateModuleName(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "module.acidcfg")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseModuleConfig(t *testing.T) {
+	filename := writeConfig(t, `{
+  "name": "json",
+  "author": "acid",
+  "version": "1.2.0",
+  "keywords": ["json", "encoding"],
+  "replace": {"http": "../http"},
+  "signers": {"http": ["SHA256:abc"]}
+}`)
+	config, warnings, err := ParseModuleConfigWithWarnings(filename)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("ParseModuleConfigWithWarnings = %v, %v", warnings, err)
+	}
+	want := ModuleConfig{
+		Name:     "json",
+		Author:   "acid",
+		Version:  "1.2.0",
+		Keywords: []string{"json", "encoding"},
+		Replace:  map[string]string{"http": "../http"},
+		Signers:  map[string][]string{"http": {"SHA256:abc"}},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
+
+func TestParseModuleConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+		message string
+	}{
+		{"trailing comma", "{\n  \"name\": \"json\",\n  \"version\": \"1.0.0\",\n}", 3, 21, "trailing comma"},
+		{"syntax", "{\n  \"name\": \"json\"\n  \"version\": \"1.0.0\"\n}", 3, 3, "invalid JSON"},
+		{"unterminated", "{\n  \"name\": \"json\",", 2, 17, "unexpected end"},
+		{"not an object", "\n  [1, 2]", 2, 3, "expected a JSON object"},
+		{"wrong type", "{\n  \"name\": \"json\",\n  \"version\": 1\n}", 3, 14, `field "version" must be a string`},
+		{"wrong element type", "{\"name\": \"json\", \"version\": \"1.0.0\", \"keywords\": [1]}", 1, 50, `field "keywords" must be an array of strings`},
+		{"missing field", "{\n  \"name\": \"json\"\n}", 1, 1, `missing required field "version"`},
+		{"empty version", "{\"name\": \"json\", \"version\": \"\"}", 1, 29, "version is empty"},
+		{"invalid name", "{\n\t\"version\": \"1.0.0\",\n\t\"name\": \"../json\"\n}", 3, 10, "module name '../json'"},
+		{"invalid replace", "{\"name\": \"json\", \"version\": \"1.0.0\",\n \"replace\": {\"a/b\": \"../b\"}}", 2, 13, "invalid replace directive"},
+		{"invalid entry", "{\"name\": \"json\", \"version\": \"1.0.0\", \"entry\": \"../main.acid\"}", 1, 47, "entry '../main.acid'"},
+		{"content after object", "{\"name\": \"json\", \"version\": \"1.0.0\"}\n{}", 2, 1, "after the closing brace"},
+		{"columns count runes", "{\"author\": \"Zoë\", \"name\": \"json\", \"version\": 2}", 1, 46, `field "version"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseModuleConfig(writeConfig(t, test.content))
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("ParseModuleConfig = %v, want a *ConfigError", err)
+			}
+			if configErr.Line != test.line || configErr.Column != test.column || !strings.Contains(configErr.Err.Error(), test.message) {
+				t.Errorf("error = %d:%d: %v, want %d:%d: ...%s...", configErr.Line, configErr.Column, configErr.Err, test.line, test.column, test.message)
+			}
+			if !strings.HasPrefix(err.Error(), configErr.File+":") {
+				t.Errorf("error %q does not start with the file name", err)
+			}
+		})
+	}
+}
+
+func TestParseModuleConfigWarnings(t *testing.T) {
+	filename := writeConfig(t, `{
+  "name": "json",
+  "verison": "1.0.0",
+  "version": "1.0.0",
+  "name": "json2"
+}`)
+	config, warnings, err := ParseModuleConfigWithWarnings(filename)
+	if err != nil {
+		t.Fatalf("ParseModuleConfigWithWarnings: %v", err)
+	}
+	if config.Name != "json2" {
+		t.Errorf("Name = %q, want the last of the repeated fields", config.Name)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("warnings = %v, want an unknown and a repeated field", warnings)
+	}
+	for i, want := range []string{`:3:3: unknown field "verison"`, `:5:3: field "name" is repeated`} {
+		if !strings.Contains(warnings[i].Error(), want) {
+			t.Errorf("warning %d = %q, want %q", i, warnings[i], want)
+		}
+	}
+}
+
+func TestWriteModuleConfigRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "module.acidcfg")
+	config := ModuleConfig{
+		Name:        "json",
+		Author:      `Ann "acid" O'Brien <ann@example.org>`,
+		Version:     "1.0.0-rc.1",
+		Description: "Parses <json> & \\escapes\\\nacross lines",
+		Keywords:    []string{"json"},
+		Replace:     map[string]string{"http": `C:\modules\http`},
+	}
+	if err := WriteModuleConfig(filename, config); err != nil {
+		t.Fatalf("WriteModuleConfig: %v", err)
+	}
+	content, _ := os.ReadFile(filename)
+	if !strings.Contains(string(content), "<ann@example.org>") {
+		t.Errorf("HTML characters were escaped:\n%s", content)
+	}
+
+	got, warnings, err := ParseModuleConfigWithWarnings(filename)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("ParseModuleConfigWithWarnings = %v, %v", warnings, err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("round trip = %+v, want %+v", got, config)
+	}
+}
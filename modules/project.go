@@ -0,0 +1,466 @@
+// Package modules manages the dependencies of an Acid project: installing,
+// restoring, upgrading and removing them, and keeping acid.lock in sync.
+//
+//	project := modules.NewProject("path/to/project", modules.Options{Output: os.Stdout})
+//	installed, err := project.Restore()
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acidlang/ace/git"
+	"github.com/acidlang/ace/license"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/registry"
+	"github.com/acidlang/ace/source"
+)
+
+// Receives the progress messages and warnings reported by a Project.
+//
+// A *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// The git operations a Project relies on. git.CLI implements them by running
+// the git executable.
+type Git interface {
+	Clone(repoURL, dir string, shallow bool) error
+	Checkout(repoPath, ref string) error
+	Fetch(repoPath, remote, ref string) error
+	CommitHash(repoPath string) string
+	CurrentBranch(repoPath string) string
+	Tags(repoPath string) []string
+	LatestCommitHash(repoURL string) string
+	RemoteURL(repoPath, remote string) string
+	IsClean(repoPath string) (bool, error)
+	TagExists(repoPath, tag string) bool
+	RemoteTagExists(repoPath, remote, tag string) bool
+	CreateTag(repoPath, tag string) error
+	PushTag(repoPath, remote, tag string) error
+	VerifySignature(repoPath, rev string, sshKeys []string) (git.Signature, error)
+}
+
+// Options for a Project. The zero value selects the defaults.
+//
+// Relative paths are resolved against the project root.
+type Options struct {
+	// Directory modules are installed into. Defaults to the manifest's
+	// pkg_dir field, or "pkg".
+	PkgDir string
+	// Lockfile path. Defaults to the manifest's lockfile field, or "acid.lock".
+	LockFile string
+	// Module configuration path, "module.acidcfg" by default.
+	Manifest string
+	// Where progress messages are written when Logger is nil. Messages are
+	// discarded when both are nil.
+	Output io.Writer
+	// Receives progress messages and warnings, one per call.
+	Logger Logger
+	// The git backend, git.CLI by default.
+	Git Git
+	// Refuse to write the lockfile or install anything it does not pin,
+	// as ACE_FROZEN does.
+	Frozen bool
+	// How many modules Restore fetches at once, 1 when zero.
+	Jobs int
+	// Registry ace publish announces releases to. Defaults to
+	// ACE_PUBLISH_REGISTRY, none when both are empty.
+	PublishRegistry string
+	// Module proxies, http(s):// or file:// URLs, tried in order for pinned
+	// versions before cloning with git.
+	Proxies []string
+	// Directory pinned module versions fetched with git are cached in, in
+	// the proxy layout, and tried first. No cache is kept when empty.
+	CacheDir string
+	// Keys allowed to sign each module's tags or commits, by module name, in
+	// addition to the manifest's signers field. See Project.Signers.
+	Signers map[string][]string
+	// Licenses modules may be added under; installs and upgrades bringing in
+	// any other license fail. The zero value allows every license.
+	LicensePolicy license.Policy
+	// The running ace version. Modules whose module.acidcfg asks for a newer
+	// ace are refused; nothing is checked when empty.
+	ToolVersion string
+	// Registries short names are resolved against, highest priority first.
+	// Defaults to ACE_REGISTRY.
+	Registries []string
+	// How long a registry request, archive download or proxy request may
+	// take, 30 seconds when zero.
+	Timeout time.Duration
+	// Rewrite rules redirecting where repositories and archives are fetched
+	// from. The default git backend applies them too.
+	Rewrites source.Rewrites
+}
+
+// An Acid project rooted at a directory, with its installed modules and lockfile.
+//
+// Relative paths in the options are resolved against the root, so a Project
+// never depends on the process working directory.
+type Project struct {
+	Root     string
+	options  Options
+	git      Git
+	logger   Logger
+	registry *registry.Client
+
+	// The layout after applying the manifest's fields and the defaults.
+	pkgDir       string
+	lockName     string
+	manifestName string
+
+	// Guards the lockfile while modules are restored in parallel.
+	lockMu sync.RWMutex
+}
+
+// A module recorded in the lockfile.
+type Module struct {
+	Name string
+	// Where the module is installed.
+	Dir string
+	// The module's own module.acidcfg, nil when it is missing or invalid.
+	Config *ModuleConfig
+	lock.LockEntry
+}
+
+// Open the project rooted at root.
+func NewProject(root string, options Options) *Project {
+	project := &Project{
+		Root:         root,
+		options:      options,
+		git:          options.Git,
+		logger:       options.Logger,
+		pkgDir:       options.PkgDir,
+		lockName:     options.LockFile,
+		manifestName: options.Manifest,
+	}
+
+	if project.manifestName == "" {
+		project.manifestName = "module.acidcfg"
+	}
+	config, _ := project.Config()
+	if project.pkgDir == "" {
+		project.pkgDir = config.PkgDir
+	}
+	if project.lockName == "" {
+		project.lockName = config.LockFile
+	}
+	if project.pkgDir == "" {
+		project.pkgDir = "pkg"
+	}
+	if project.lockName == "" {
+		project.lockName = "acid.lock"
+	}
+
+	if project.git == nil {
+		project.git = git.CLI{Rewrites: options.Rewrites}
+	}
+	if project.options.Timeout == 0 {
+		project.options.Timeout = 30 * time.Second
+	}
+	project.registry = &registry.Client{Sources: options.Registries, Timeout: project.options.Timeout}
+	if project.logger == nil {
+		output := options.Output
+		if output == nil {
+			output = io.Discard
+		}
+		project.logger = writerLogger{output}
+	}
+	return project
+}
+
+// Find the root of the project containing dir, by searching dir and then its
+// parents for the manifest, lockfile or ace.work named by options.
+//
+// The root is returned relative to dir when dir is relative, e.g. "..". When
+// no parent holds one of those files, dir itself is returned with ok unset.
+func FindProjectRoot(dir string, options Options) (root string, ok bool) {
+	markers := []string{options.Manifest, options.LockFile, "ace.work"}
+	if options.Manifest == "" {
+		markers[0] = "module.acidcfg"
+	}
+	if options.LockFile == "" {
+		markers[1] = "acid.lock"
+	}
+
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, false
+	}
+
+	for current := start; ; current = filepath.Dir(current) {
+		for _, marker := range markers {
+			// An absolute lockfile or manifest path says nothing about the root.
+			if filepath.IsAbs(marker) {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(current, marker)); err == nil {
+				if filepath.IsAbs(dir) {
+					return current, true
+				}
+				rel, err := filepath.Rel(start, current)
+				if err != nil {
+					return current, true
+				}
+				return filepath.Join(dir, rel), true
+			}
+		}
+
+		if filepath.Dir(current) == current {
+			return dir, false
+		}
+	}
+}
+
+// Check whether the project refuses to change its lockfile.
+func (p *Project) Frozen() bool {
+	return p.options.Frozen || lock.IsFrozen()
+}
+
+// Get the directory modules are installed into.
+func (p *Project) PkgDir() string {
+	return p.resolve(p.pkgDir)
+}
+
+// Get the path of the project's lockfile.
+func (p *Project) LockPath() string {
+	return p.resolve(p.lockName)
+}
+
+// Get the path of the project's module.acidcfg.
+func (p *Project) ManifestPath() string {
+	return p.resolve(p.manifestName)
+}
+
+// Get the directory a module is installed in.
+func (p *Project) ModuleDir(moduleName string) string {
+	return filepath.Join(p.PkgDir(), moduleName)
+}
+
+// Read the project's own module configuration.
+func (p *Project) Config() (ModuleConfig, error) {
+	return ParseModuleConfig(p.ManifestPath())
+}
+
+// Get the warnings about the project's own module configuration, such as
+// fields ace does not know. A missing or invalid one has none.
+func (p *Project) ConfigWarnings() []error {
+	_, warnings, err := ParseModuleConfigWithWarnings(p.ManifestPath())
+	if err != nil {
+		return nil
+	}
+	return warnings
+}
+
+// Write a module.acidcfg for a new project, named after its directory.
+func (p *Project) Init() error {
+	root, err := filepath.Abs(p.Root)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", p.Root, err)
+	}
+
+	projName := sanitizeModuleName(strings.ToLower(strings.ReplaceAll(filepath.Base(root), " ", "_")))
+	author := os.Getenv("USER")
+	if author == "" {
+		author = os.Getenv("USERNAME")
+	}
+	if author == "" {
+		author = "unknown"
+	}
+
+	config := ModuleConfig{
+		Name:    projName,
+		Author:  author,
+		Version: "0.1.0",
+	}
+
+	manifest := p.ManifestPath()
+	if _, err := os.Stat(manifest); err == nil {
+		return fmt.Errorf("%s already exists, aborting", manifest)
+	}
+
+	if err := WriteModuleConfig(manifest, config); err != nil {
+		return fmt.Errorf("error writing %s: %v", manifest, err)
+	}
+
+	p.logf("Initialized module.")
+	return nil
+}
+
+// List the modules recorded in the lockfile, sorted by name.
+func (p *Project) List() ([]Module, error) {
+	lockFile, err := p.readLock()
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make([]Module, 0, len(lockFile))
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		modules = append(modules, p.module(moduleName, lockFile[moduleName]))
+	}
+	return modules, nil
+}
+
+// Get a single module recorded in the lockfile.
+func (p *Project) Info(moduleName string) (Module, error) {
+	lockFile, err := p.readLock()
+	if err != nil {
+		return Module{}, err
+	}
+
+	entry, exists := lockFile[moduleName]
+	if !exists {
+		return Module{}, fmt.Errorf("%w: module '%s' is not in the lock file", ErrNotFound, moduleName)
+	}
+	return p.module(moduleName, entry), nil
+}
+
+// The project and the modules it depends on.
+type Graph struct {
+	Name         string
+	Dependencies []Module
+}
+
+// Get the project's dependencies, as recorded in the lockfile.
+func (p *Project) Graph() (Graph, error) {
+	modules, err := p.List()
+	if err != nil {
+		return Graph{}, err
+	}
+	if len(modules) == 0 {
+		return Graph{}, fmt.Errorf("%w: no modules installed", ErrNotFound)
+	}
+	return Graph{Name: p.name(), Dependencies: modules}, nil
+}
+
+func (p *Project) module(moduleName string, entry lock.LockEntry) Module {
+	module := Module{Name: moduleName, Dir: p.ModuleDir(moduleName), LockEntry: entry}
+	if config, err := ParseModuleConfig(filepath.Join(module.Dir, "module.acidcfg")); err == nil {
+		module.Config = &config
+	}
+	return module
+}
+
+// Get the project's declared module name, falling back to its directory name.
+func (p *Project) name() string {
+	if config, err := p.Config(); err == nil {
+		return config.Name
+	}
+	if root, err := filepath.Abs(p.Root); err == nil {
+		return filepath.Base(root)
+	}
+	return "current-module"
+}
+
+func (p *Project) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.Root, path)
+}
+
+// Read the lockfile, reporting a missing file as ErrNotFound.
+func (p *Project) readLock() (lock.LockFile, error) {
+	p.lockMu.RLock()
+	defer p.lockMu.RUnlock()
+
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: no %s file found", ErrNotFound, p.lockName)
+		}
+		return nil, fmt.Errorf("error reading %s: %v", p.lockName, err)
+	}
+	return lockFile, nil
+}
+
+// Read the lockfile, treating a missing or unreadable one as empty.
+func (p *Project) lockFile() lock.LockFile {
+	p.lockMu.RLock()
+	defer p.lockMu.RUnlock()
+
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		return make(lock.LockFile)
+	}
+	return lockFile
+}
+
+func (p *Project) updateLock(moduleName string, entry lock.LockEntry) error {
+	if p.Frozen() {
+		return lock.ErrFrozen
+	}
+	p.lockMu.Lock()
+	defer p.lockMu.Unlock()
+	return lock.UpdateLockFile(p.LockPath(), moduleName, entry)
+}
+
+func (p *Project) removeFromLock(moduleName string) error {
+	if p.Frozen() {
+		return lock.ErrFrozen
+	}
+	p.lockMu.Lock()
+	err := lock.RemoveFromLockFile(p.LockPath(), moduleName)
+	p.lockMu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.logf("Removed %s from lock file.", moduleName)
+	return nil
+}
+
+func (p *Project) renameLockEntry(oldName, newName string) error {
+	if p.Frozen() {
+		return lock.ErrFrozen
+	}
+	p.lockMu.Lock()
+	defer p.lockMu.Unlock()
+	return lock.RenameLockEntry(p.LockPath(), oldName, newName)
+}
+
+// Build a lock entry describing the clone in dir.
+func (p *Project) entryFor(dir, repoURL string) lock.LockEntry {
+	return lock.LockEntry{
+		Repo:       repoURL,
+		CommitHash: p.git.CommitHash(dir),
+		Branch:     p.git.CurrentBranch(dir),
+		Tags:       p.git.Tags(dir),
+	}
+}
+
+// Log a message, hiding any credentials in the URLs and errors it mentions.
+func (p *Project) logf(format string, args ...any) {
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			args[i] = source.Redact(arg)
+		case error:
+			args[i] = source.Redact(arg.Error())
+		}
+	}
+	p.logger.Printf(format, args...)
+}
+
+type writerLogger struct {
+	w io.Writer
+}
+
+func (l writerLogger) Printf(format string, args ...any) {
+	fmt.Fprintf(l.w, strings.TrimSuffix(format, "\n")+"\n", args...)
+}
+
+func sortedModuleNames(lockFile lock.LockFile) []string {
+	names := make([]string, 0, len(lockFile))
+	for moduleName := range lockFile {
+		names = append(names, moduleName)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,212 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/acidlang/ace/lock"
+)
+
+// Isolate git from the user's configuration and give commits an author.
+func gitEnv(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Ada")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ada@example.org")
+	t.Setenv("GIT_COMMITTER_NAME", "Ada")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ada@example.org")
+	t.Setenv("ACE_FROZEN", "")
+	t.Setenv("ACE_REGISTRY", "")
+	t.Setenv("ACE_PUBLISH_REGISTRY", "")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Create a repository for the module name under dir, with a commit tagged
+// with each version, and return its path.
+func newRepo(t *testing.T, dir, name string, versions ...string) string {
+	t.Helper()
+	repo := filepath.Join(dir, name)
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "init", "-q", "-b", "main")
+	for _, version := range versions {
+		commitVersion(t, repo, name, version)
+		runGit(t, repo, "tag", version)
+	}
+	return repo
+}
+
+// Commit a new version of the module in repo.
+func commitVersion(t *testing.T, repo, name, version string) string {
+	t.Helper()
+	manifest := fmt.Sprintf("{\n  \"name\": %q,\n  \"version\": %q\n}\n", name, strings.TrimPrefix(version, "v"))
+	if err := os.WriteFile(filepath.Join(repo, "module.acidcfg"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, name+".acid"), []byte("// "+name+" "+version+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", version)
+	return runGit(t, repo, "rev-parse", "HEAD")
+}
+
+// Create a project named app in a new directory.
+func newTestProject(t *testing.T, options Options) *Project {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "module.acidcfg"), []byte("{\n  \"name\": \"app\",\n  \"version\": \"0.1.0\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewProject(root, options)
+}
+
+// Read the module file a version of newRepo wrote.
+func installedVersion(t *testing.T, p *Project, moduleName string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(p.ModuleDir(moduleName), moduleName+".acid"))
+	if err != nil {
+		t.Fatalf("%s is not installed: %v", moduleName, err)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(content), "// "+moduleName))
+}
+
+func readLock(t *testing.T, p *Project) lock.LockFile {
+	t.Helper()
+	lockFile, err := lock.ParseLockFile(p.LockPath())
+	if err != nil {
+		t.Fatalf("reading %s: %v", p.LockPath(), err)
+	}
+	return lockFile
+}
+
+func TestInstallRestoreUpgrade(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+	repo := newRepo(t, remotes, "json", "v1.0.0", "v1.1.0")
+	p := newTestProject(t, Options{})
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{"v1.0.0", "v1.0.0"},
+		{"", "v1.1.0"},
+		{runGit(t, repo, "rev-parse", "v1.0.0"), "v1.0.0"},
+	}
+	for _, test := range tests {
+		module, err := p.Install(repo, test.version)
+		if err != nil {
+			t.Fatalf("Install(%q): %v", test.version, err)
+		}
+		if module.Name != "json" || installedVersion(t, p, "json") != test.want {
+			t.Errorf("Install(%q) installed %s %s, want json %s", test.version, module.Name, installedVersion(t, p, "json"), test.want)
+		}
+		if entry := readLock(t, p)["json"]; entry.CommitHash != runGit(t, repo, "rev-parse", test.want) || entry.RequestedVersion != test.version {
+			t.Errorf("Install(%q) locked %+v", test.version, entry)
+		}
+	}
+
+	// Restore brings back the locked commit, not the latest one.
+	if err := os.RemoveAll(p.PkgDir()); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := p.Restore()
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("Restore = %+v, %v", restored, err)
+	}
+	if got := installedVersion(t, p, "json"); got != "v1.0.0" {
+		t.Errorf("Restore installed %s, want the locked v1.0.0", got)
+	}
+
+	latest := commitVersion(t, repo, "json", "v1.2.0")
+	upgrades, err := p.Upgrade()
+	if err != nil || len(upgrades) != 1 || !upgrades[0].Changed() {
+		t.Fatalf("Upgrade = %+v, %v", upgrades, err)
+	}
+	if got := installedVersion(t, p, "json"); got != "v1.2.0" || readLock(t, p)["json"].CommitHash != latest {
+		t.Errorf("Upgrade installed %s, want the latest commit", got)
+	}
+
+	upgrades, err = p.Upgrade()
+	if err != nil || len(upgrades) != 1 || upgrades[0].Changed() {
+		t.Errorf("Upgrade when up to date = %+v, %v", upgrades, err)
+	}
+
+	if err := p.Remove("json"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(p.ModuleDir("json")); !os.IsNotExist(err) {
+		t.Errorf("Remove left %s behind", p.ModuleDir("json"))
+	}
+	if _, locked := readLock(t, p)["json"]; locked {
+		t.Errorf("Remove left json in the lockfile")
+	}
+}
+
+func TestInstallFailures(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+	repo := newRepo(t, remotes, "json", "v1.0.0")
+	p := newTestProject(t, Options{})
+
+	tests := []struct {
+		name    string
+		input   string
+		version string
+		want    error
+	}{
+		{"missing repository", filepath.Join(remotes, "none"), "", ErrCloneFailed},
+		{"missing version", repo, "v9.9.9", ErrCheckoutFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := p.Install(test.input, test.version); !errors.Is(err, test.want) {
+				t.Errorf("Install = %v, want %v", err, test.want)
+			}
+			if _, err := os.Stat(p.ModuleDir("json")); err == nil {
+				t.Errorf("a failed install left pkg/json behind")
+			}
+		})
+	}
+}
+
+func TestProjectLayoutOptions(t *testing.T) {
+	gitEnv(t)
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	p := newTestProject(t, Options{PkgDir: "deps", LockFile: "locks/ace.lock"})
+	os.MkdirAll(filepath.Join(p.Root, "locks"), 0755)
+
+	if _, err := p.Install(repo, "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	for _, path := range []string{filepath.Join(p.Root, "deps", "json", "json.acid"), filepath.Join(p.Root, "locks", "ace.lock")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s missing: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(p.Root, "acid.lock")); err == nil {
+		t.Errorf("acid.lock was written despite the LockFile option")
+	}
+}
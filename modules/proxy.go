@@ -0,0 +1,139 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/proxy"
+)
+
+// Fetch repoURL at version into dir, from the cache or a proxy when one has
+// it, and otherwise by cloning it with git. Only pinned versions, tags and
+// full commit hashes, are looked up in proxies; branches always use git.
+//
+// Returns the commit fetched, or "" for shallow HEAD clones, and whether it
+// came from a proxy rather than git. useProxy false always clones.
+func (p *Project) fetchModule(repoURL, version, dir string, useProxy bool) (string, bool, error) {
+	if useProxy {
+		if info, ok := p.fetchFromProxy(repoURL, version, dir); ok {
+			return info.Commit, true, nil
+		}
+	}
+
+	commitHash, err := p.cloneModule(repoURL, version, dir)
+	if err == nil && version != "" {
+		p.cacheModule(repoURL, commitHash, dir, version)
+	}
+	return commitHash, false, err
+}
+
+// Fetch the commit a lock entry pins into dir from the cache or a proxy, by
+// commit or else by its requested version.
+func (p *Project) fetchLocked(repoURL string, entry lock.LockEntry, dir string) (bool, error) {
+	info, ok := p.fetchFromProxy(repoURL, entry.CommitHash, dir)
+	if !ok && entry.RequestedVersion != "" {
+		info, ok = p.fetchFromProxy(repoURL, entry.RequestedVersion, dir)
+		if ok && info.Commit != entry.CommitHash {
+			p.logf("Warning: proxies serve %s at %s, but %s pins %s, using git", entry.RequestedVersion, shortHash(info.Commit), p.lockName, shortHash(entry.CommitHash))
+			os.RemoveAll(dir)
+			return false, nil
+		}
+	}
+	if !ok {
+		return false, nil
+	}
+
+	if entry.Checksum != "" {
+		if sum, err := checksum.Dir(dir); err != nil || !checksum.Equal(sum, entry.Checksum) {
+			os.RemoveAll(dir)
+			return true, fmt.Errorf("%w: content served for %s does not match the checksum %s pins", ErrVerificationFailed, repoURL, p.lockName)
+		}
+	}
+	return true, nil
+}
+
+// Try the cache and then each proxy for a module version, extracting the
+// first one found into dir.
+//
+// Proxy failures are only logged, since git remains as a fallback.
+func (p *Project) fetchFromProxy(repoURL, version, dir string) (proxy.Info, bool) {
+	module, ok := proxy.ModulePath(repoURL)
+	if !ok || !proxy.Pinned(version) {
+		return proxy.Info{}, false
+	}
+
+	client := proxy.Client{Timeout: p.options.Timeout}
+	for _, base := range p.proxies() {
+		info, err := client.FetchInfo(base, module, version)
+		if err == nil && info.Commit == "" {
+			err = fmt.Errorf("no commit listed for %s@%s", module, version)
+		}
+		if err == nil {
+			os.RemoveAll(dir)
+			err = client.Download(base, module, version, dir)
+		}
+		if err == nil && info.Checksum != "" {
+			if sum, sumErr := checksum.Dir(dir); sumErr != nil || !checksum.Equal(sum, info.Checksum) {
+				err = fmt.Errorf("%s@%s does not match its listed checksum %s", module, version, info.Checksum)
+			}
+		}
+
+		if err != nil {
+			os.RemoveAll(dir)
+			if !errors.Is(err, proxy.ErrNotFound) {
+				p.logf("Warning: proxy %s: %v", base, err)
+			}
+			continue
+		}
+
+		p.logf("Fetched %s@%s from %s", module, version, base)
+		return info, true
+	}
+	return proxy.Info{}, false
+}
+
+// Get the proxies to try: the cache directory, then Options.Proxies.
+func (p *Project) proxies() []string {
+	var bases []string
+	if p.options.CacheDir != "" {
+		if dir, err := filepath.Abs(p.options.CacheDir); err == nil {
+			bases = append(bases, "file://"+filepath.ToSlash(dir))
+		}
+	}
+	return append(bases, p.options.Proxies...)
+}
+
+// Add a module fetched with git to the cache, under its commit and those of
+// the given versions that are tags of it, so that later installs and restores
+// need no git. Branches move, so they are never cached.
+func (p *Project) cacheModule(repoURL, commitHash, dir string, versions ...string) {
+	module, ok := proxy.ModulePath(repoURL)
+	if !ok || p.options.CacheDir == "" || commitHash == "" {
+		return
+	}
+
+	sum, err := checksum.Dir(dir)
+	if err != nil {
+		p.logf("Warning: could not cache %s: %v", module, err)
+		return
+	}
+
+	tags := p.git.Tags(dir)
+	stored := map[string]bool{}
+	for _, version := range append(versions, commitHash) {
+		if stored[version] || version != commitHash && (!slices.Contains(tags, version) || !proxy.Pinned(version)) {
+			continue
+		}
+		stored[version] = true
+
+		info := proxy.Info{Version: version, Commit: commitHash, Checksum: sum}
+		if err := proxy.Store(p.options.CacheDir, module, info, dir); err != nil {
+			p.logf("Warning: could not cache %s@%s: %v", module, version, err)
+		}
+	}
+}
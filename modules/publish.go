@@ -0,0 +1,136 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/registry"
+	"github.com/acidlang/ace/semver"
+	"github.com/acidlang/ace/source"
+)
+
+// Validate the project's module and release it as a v<version> git tag.
+//
+// The tag is pushed to origin and, when ACE_PUBLISH_REGISTRY is set, announced
+// to that registry. With dryRun set, only the planned steps are logged.
+func (p *Project) Publish(dryRun bool) (registry.Release, error) {
+	var release registry.Release
+
+	config, err := p.Config()
+	if err != nil {
+		return release, fmt.Errorf("%w: no valid %s file found: %v", ErrInvalidConfig, p.manifestName, err)
+	}
+
+	if err := ValidateModuleName(config.Name); err != nil {
+		return release, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if !semver.Valid(config.Version) {
+		return release, fmt.Errorf("%w: version '%s' is not a valid semantic version (e.g. 1.2.3)", ErrInvalidConfig, config.Version)
+	}
+
+	commitHash := p.git.CommitHash(p.Root)
+	if commitHash == "" {
+		return release, fmt.Errorf("module is not a git repository with at least one commit")
+	}
+
+	clean, err := p.git.IsClean(p.Root)
+	if err != nil {
+		return release, fmt.Errorf("error checking working tree: %v", err)
+	}
+	if !clean {
+		return release, fmt.Errorf("working tree has uncommitted changes, commit or stash them first")
+	}
+
+	tag := "v" + strings.TrimPrefix(config.Version, "v")
+	if p.git.TagExists(p.Root, tag) {
+		return release, fmt.Errorf("%w: tag %s already exists, bump the version in module.acidcfg", ErrConflict, tag)
+	}
+
+	repoURL := p.git.RemoteURL(p.Root, "origin")
+	if repoURL == "" {
+		return release, fmt.Errorf("no 'origin' remote configured to push the release to")
+	}
+	if p.git.RemoteTagExists(p.Root, "origin", tag) {
+		return release, fmt.Errorf("%w: tag %s already exists on origin, bump the version in module.acidcfg", ErrConflict, tag)
+	}
+
+	registrySource := p.options.PublishRegistry
+	if registrySource == "" {
+		registrySource = os.Getenv("ACE_PUBLISH_REGISTRY")
+	}
+
+	// The registry is public, so never announce the credentials of origin.
+	release = registry.Release{
+		Name:    config.Name,
+		Repo:    source.StripCredentials(repoURL),
+		Version: tag,
+		Commit:  commitHash,
+	}
+
+	// Hash what an install of the tag will check, before anything is pushed.
+	if registrySource != "" {
+		release.Checksum, err = p.releaseChecksum(commitHash)
+		if err != nil {
+			return release, fmt.Errorf("error computing checksum: %v", err)
+		}
+	}
+
+	p.logf("Module %s %s is ready to publish (commit %s)", config.Name, tag, commitHash[:7])
+
+	if dryRun {
+		p.logf("Dry run, would:")
+		p.logf("  - create tag %s at %s", tag, commitHash[:7])
+		p.logf("  - push %s to origin (%s)", tag, release.Repo)
+		if registrySource != "" {
+			p.logf("  - notify registry %s", registrySource)
+		}
+		return release, nil
+	}
+
+	if err := p.git.CreateTag(p.Root, tag); err != nil {
+		return release, fmt.Errorf("error creating tag %s: %v", tag, err)
+	}
+	p.logf("Created tag %s", tag)
+
+	if err := p.git.PushTag(p.Root, "origin", tag); err != nil {
+		p.logf("The tag exists locally, push it with 'git push origin %s' once resolved.", tag)
+		return release, fmt.Errorf("error pushing tag %s to origin: %v", tag, err)
+	}
+	p.logf("Pushed %s to origin", tag)
+
+	if registrySource == "" {
+		return release, nil
+	}
+
+	if err := p.registry.Publish(registrySource, release); err != nil {
+		return release, fmt.Errorf("error notifying registry %s: %v", registrySource, err)
+	}
+	p.logf("Notified registry %s", registrySource)
+	return release, nil
+}
+
+// Hash a clean clone of the commit being released the way installs verify
+// it, so symlinks, submodules and untracked files count the same.
+func (p *Project) releaseChecksum(commitHash string) (string, error) {
+	tmp, err := os.MkdirTemp("", "ace-publish-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	root, err := filepath.Abs(p.Root)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(tmp, "module")
+	if err := p.git.Clone(root, dir, false); err != nil {
+		return "", fmt.Errorf("error cloning %s: %v", root, err)
+	}
+	if err := p.git.Checkout(dir, commitHash); err != nil {
+		return "", fmt.Errorf("error checking out %s: %v", commitHash, err)
+	}
+	return checksum.Dir(dir)
+}
@@ -0,0 +1,166 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Get the replace directives declared in the project's module.acidcfg.
+func (p *Project) Replacements() map[string]string {
+	config, err := p.Config()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			p.logf("Warning: ignoring replace directives, %s is invalid: %v", p.manifestName, err)
+		}
+		return map[string]string{}
+	}
+	if config.Replace == nil {
+		return map[string]string{}
+	}
+	return config.Replace
+}
+
+// Check whether a replacement target is a local path rather than a repository URL.
+func (p *Project) IsLocalReplacement(target string) bool {
+	if strings.HasPrefix(target, ".") || strings.HasPrefix(target, "~") || filepath.IsAbs(target) {
+		return true
+	}
+	if strings.Contains(target, "://") || strings.Contains(target, "@") {
+		return false
+	}
+	info, err := os.Stat(p.localPath(target))
+	return err == nil && info.IsDir()
+}
+
+// Describe a replacement target for display, e.g. "../json (local path)".
+func (p *Project) DescribeReplacement(target string) string {
+	if p.IsLocalReplacement(target) {
+		return target + " (local path)"
+	}
+	return target + " (repository)"
+}
+
+// Install a module from a local directory named by a replace directive.
+//
+// The directory is symlinked into the install directory, or copied when
+// symlinks are unavailable, and the lock entry keeps the original repository
+// alongside the replacement.
+func (p *Project) InstallLocalReplacement(moduleName, localPath, repoURL string) (Module, error) {
+	sourceDir := p.localPath(localPath)
+	moduleFile := filepath.Join(sourceDir, "module.acidcfg")
+	if _, err := os.Stat(moduleFile); err != nil {
+		return Module{}, fmt.Errorf("%w: no module.acidcfg found in %s", ErrNotFound, localPath)
+	}
+
+	if err := ValidateModuleName(moduleName); err != nil {
+		return Module{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if err := p.CheckNameClash(moduleName, repoURL); err != nil {
+		return Module{}, err
+	}
+
+	config, err := ParseModuleConfig(moduleFile)
+	if err != nil {
+		return Module{}, fmt.Errorf("%w: error parsing module config in %s: %v", ErrInvalidConfig, localPath, err)
+	}
+	if config.Name != moduleName {
+		p.logf("Warning: %s declares module name '%s', expected '%s'", localPath, config.Name, moduleName)
+	}
+
+	targetDir := p.ModuleDir(moduleName)
+	if err := p.linkLocalModule(sourceDir, targetDir); err != nil {
+		return Module{}, err
+	}
+
+	p.logf("Linked %s to %s", localPath, targetDir)
+
+	entry := p.entryFor(sourceDir, repoURL)
+	entry.Replace = localPath
+
+	// Local replacements are never pinned, so a frozen lock already matches.
+	if !p.Frozen() {
+		if err := p.updateLock(moduleName, entry); err != nil {
+			return Module{}, err
+		}
+	}
+	return p.module(moduleName, entry), nil
+}
+
+// Resolve a local replacement path against the project root.
+func (p *Project) localPath(path string) string {
+	return p.resolve(expandHome(path))
+}
+
+// Link or copy sourceDir to targetDir, replacing whatever was there only once
+// the new link or copy exists.
+func (p *Project) linkLocalModule(sourceDir, targetDir string) error {
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(targetDir), err)
+	}
+
+	newDir := filepath.Join(filepath.Dir(targetDir), ".new_"+filepath.Base(targetDir))
+	os.RemoveAll(newDir)
+	if err := os.Symlink(absSource, newDir); err != nil {
+		if err := copyDir(absSource, newDir); err != nil {
+			os.RemoveAll(newDir)
+			return err
+		}
+	}
+	return p.moveInto(newDir, targetDir)
+}
+
+// Copy a module's files, leaving out its git metadata.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
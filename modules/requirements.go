@@ -0,0 +1,37 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/acidlang/ace/semver"
+)
+
+// Refuse a module that needs a newer ace than Options.ToolVersion, and warn
+// about one that raises the project's minimum Acid version or lacks the
+// entry file it declares.
+func (p *Project) checkRequirements(moduleName, dir string, config ModuleConfig) error {
+	if config.Ace != "" && p.options.ToolVersion != "" {
+		required, _ := semver.Parse(config.Ace)
+		if running, ok := semver.Parse(p.options.ToolVersion); ok && semver.Compare(required, running) > 0 {
+			return fmt.Errorf("%w: %s needs ace %s or newer, this is ace %s", ErrIncompatible, moduleName, config.Ace, p.options.ToolVersion)
+		}
+	}
+
+	if config.Acid != "" {
+		if project, err := p.Config(); err == nil && project.Acid != "" {
+			required, _ := semver.Parse(config.Acid)
+			if targeted, _ := semver.Parse(project.Acid); semver.Compare(required, targeted) > 0 {
+				p.logf("Warning: %s needs Acid %s, but the project declares acid %s", moduleName, config.Acid, project.Acid)
+			}
+		}
+	}
+
+	if config.Entry != "" {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(config.Entry))); err != nil {
+			p.logf("Warning: %s declares entry %s, which it does not contain", moduleName, config.Entry)
+		}
+	}
+	return nil
+}
@@ -1,85 +1,237 @@
 package modules
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 
-	"github.com/acidlang/ace/cmds"
+	"github.com/acidlang/ace/archive"
 	"github.com/acidlang/ace/lock"
 )
 
-func RestoreFromLockFile() {
-	lockFile, err := lock.ParseLockFile("acid.lock")
+// Install every module recorded in the lockfile at its locked commit, up to
+// Options.Jobs at a time.
+//
+// Returns the modules that were restored, and an *OperationError listing
+// the rest.
+func (p *Project) Restore() ([]Module, error) {
+	lockFile, err := p.readLock()
 	if err != nil {
-		fmt.Println("No acid.lock found.")
-		os.Exit(1)
-	}
-
-	for moduleName, entry := range lockFile {
-		var (
-			repoURL          = entry.Repo
-			commitHash       = entry.CommitHash
-			requestedVersion = entry.RequestedVersion
-			parts            = strings.Split(repoURL, "/")
-			repoName         = strings.TrimSuffix(parts[len(parts)-1], ".git")
-			cloneDir         = "tmp_" + repoName
-		)
-
-		fmt.Printf("Restoring %s from %s\n", moduleName, repoURL)
-
-		if commitHash != "" && len(commitHash) >= 7 {
-			fmt.Printf("  Target commit: %s\n", commitHash[:7])
-			if requestedVersion != "" {
-				fmt.Printf("  Original version: %s\n", requestedVersion)
+		return nil, err
+	}
+
+	if p.Frozen() {
+		if problems := p.CheckFrozen(lockFile); len(problems) > 0 {
+			return nil, frozenError(problems)
+		}
+	}
+
+	var (
+		replacements = p.Replacements()
+		moduleNames  = sortedModuleNames(lockFile)
+		modules      = make([]Module, len(moduleNames))
+		errs         = make([]error, len(moduleNames))
+		jobs         = make(chan struct{}, max(p.options.Jobs, 1))
+		wg           sync.WaitGroup
+	)
+
+	for i, moduleName := range moduleNames {
+		jobs <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-jobs }()
+			modules[i], errs[i] = p.restoreModule(moduleName, lockFile[moduleName], replacements)
+			if errs[i] != nil {
+				p.logf("Error: %v", errs[i])
 			}
+		}()
+	}
+	wg.Wait()
+
+	// Report in lockfile order however the jobs finished.
+	summary := failureSummary{operation: "restore"}
+	var restored []Module
+	for i, moduleName := range moduleNames {
+		if modules[i].Dir != "" {
+			restored = append(restored, modules[i])
 		}
+		summary.add(moduleName, errs[i])
+	}
+	return restored, summary.err()
+}
 
-		err := cmds.RunCommand(fmt.Sprintf("git clone %s %s", repoURL, cloneDir))
+// Restore a single lock entry, honoring the given replace directives.
+//
+// When the locked commit could not be checked out outside frozen mode, the
+// module is still restored at HEAD and returned along with ErrCheckoutFailed.
+func (p *Project) restoreModule(moduleName string, entry lock.LockEntry, replacements map[string]string) (Module, error) {
+	if err := ValidateModuleName(moduleName); err != nil {
+		return Module{}, fmt.Errorf("%w: refusing to restore lock entry: %v", ErrInvalidConfig, err)
+	}
+
+	repoURL := entry.Repo
+	replacement, replaced := replacements[moduleName]
+
+	if replaced && p.IsLocalReplacement(replacement) {
+		p.logf("Restoring %s from local path %s", moduleName, replacement)
+		module, err := p.InstallLocalReplacement(moduleName, replacement, repoURL)
 		if err != nil {
-			fmt.Printf("Error cloning %s: %v\n", repoURL, err)
-			continue
+			return Module{}, fmt.Errorf("error linking %s: %w", replacement, err)
 		}
+		return module, nil
+	}
 
-		if commitHash != "" {
-			cwd, _ := os.Getwd()
-			os.Chdir(cloneDir)
-			err := cmds.RunCommand(fmt.Sprintf("git checkout %s", commitHash))
-			os.Chdir(cwd)
+	if replaced {
+		repoURL = replacement
+	} else if entry.Replace != "" {
+		p.logf("Note: %s is no longer replaced, restoring from %s", moduleName, repoURL)
+	}
 
-			if err != nil {
-				fmt.Printf("Warning: Could not checkout commit %s for %s\n", commitHash, moduleName)
-			}
-		}
+	if archive.IsArchive(repoURL) {
+		return p.restoreArchive(moduleName, repoURL, entry, replacement)
+	}
 
-		moduleFile := filepath.Join(cloneDir, "module.acidcfg")
-		if _, err := os.Stat(moduleFile); err != nil {
-			fmt.Printf("No module.acidcfg found for %s, skipping.\n", moduleName)
-			os.RemoveAll(cloneDir)
-			continue
+	var (
+		commitHash       = entry.CommitHash
+		requestedVersion = entry.RequestedVersion
+		// Named after the lock entry rather than the repository, so modules
+		// restored in parallel never share a clone.
+		cloneDir = p.resolve("tmp_" + moduleName)
+	)
+
+	// A different source means the locked commit may not exist there, so
+	// fall back to the requested version or HEAD of the new source.
+	if !p.options.Rewrites.Same(repoURL, replacedSource(entry)) {
+		commitHash = requestedVersion
+	}
+
+	p.logf("Restoring %s from %s", moduleName, repoURL)
+
+	if commitHash != "" && len(commitHash) >= 7 {
+		p.logf("  Target commit: %s", commitHash[:7])
+		if requestedVersion != "" {
+			p.logf("  Original version: %s", requestedVersion)
 		}
+	}
 
-		config, err := ParseModuleConfig(moduleFile)
+	// A pinned commit of the locked source can come from the cache or a
+	// proxy, which needs no git.
+	proxied := false
+	if p.options.Rewrites.Same(repoURL, replacedSource(entry)) && commitHash != "" && !p.requiresSignature(moduleName) {
+		fetched, err := p.fetchLocked(repoURL, entry, cloneDir)
 		if err != nil {
-			fmt.Printf("Error parsing module config for %s: %v\n", moduleName, err)
-			os.RemoveAll(cloneDir)
-			continue
+			return Module{}, err
+		}
+		proxied = fetched
+	}
+
+	var checkoutErr error
+	if !proxied {
+		checkoutErr = p.cloneLocked(moduleName, repoURL, commitHash, requestedVersion, cloneDir)
+		if checkoutErr != nil && (p.Frozen() || !errors.Is(checkoutErr, ErrCheckoutFailed)) {
+			return Module{}, checkoutErr
 		}
+	}
 
-		targetDir := filepath.Join("pkg", config.Name)
+	moduleFile := filepath.Join(cloneDir, "module.acidcfg")
+	if _, err := os.Stat(moduleFile); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, fmt.Errorf("%w: no module.acidcfg found in %s", ErrInvalidConfig, repoURL)
+	}
 
-		if _, err := os.Stat(targetDir); err == nil {
-			os.RemoveAll(targetDir)
+	config, err := ParseModuleConfig(moduleFile)
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, fmt.Errorf("%w: error parsing module config: %v", ErrInvalidConfig, err)
+	}
+
+	// A module that fell back to HEAD is not the version acid.sum knows.
+	if checkoutErr == nil && commitHash != "" {
+		fetched := commitHash
+		if !proxied {
+			fetched = p.git.CommitHash(cloneDir)
+		}
+		if err := p.verifySum(repoURL, cloneDir, fetched, sumVersions(entry)...); err != nil {
+			os.RemoveAll(cloneDir)
+			return Module{}, err
 		}
+	}
 
-		os.MkdirAll(filepath.Dir(targetDir), 0755)
-		err = os.Rename(cloneDir, targetDir)
-		if err != nil {
-			fmt.Printf("Error moving %s to %s: %v\n", cloneDir, targetDir, err)
-			continue
+	signer, err := p.verifySignature(moduleName, cloneDir, requestedVersion)
+	if err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+	if err := p.checkRequirements(moduleName, cloneDir, config); err != nil {
+		os.RemoveAll(cloneDir)
+		return Module{}, err
+	}
+
+	// Restore under the lock key, so the directory always matches it. A
+	// module renamed upstream is left for doctor to reconcile.
+	targetDir := p.ModuleDir(moduleName)
+	if err := p.moveInto(cloneDir, targetDir); err != nil {
+		return Module{}, err
+	}
+
+	p.logf("Restored %s to %s", moduleName, targetDir)
+
+	module := Module{Name: moduleName, Dir: targetDir, Config: &config, LockEntry: entry}
+	if !p.options.Rewrites.Same(repoURL, replacedSource(entry)) {
+		newEntry := p.entryFor(targetDir, entry.Repo)
+		newEntry.RequestedVersion = requestedVersion
+		newEntry.Signer = signer
+		if replaced {
+			newEntry.Replace = replacement
+		}
+		module.LockEntry = newEntry
+		if err := p.updateLock(moduleName, newEntry); err != nil {
+			return module, fmt.Errorf("error updating lockfile: %w", err)
+		}
+	} else if signer != entry.Signer && !p.Frozen() {
+		// Record who signed the module, or that a former signer was rotated.
+		module.LockEntry.Signer = signer
+		if err := p.updateLock(moduleName, module.LockEntry); err != nil {
+			return module, fmt.Errorf("error updating lockfile: %w", err)
 		}
+	}
+
+	return module, checkoutErr
+}
+
+// Clone repoURL into cloneDir for a restore and check out commitHash, adding
+// it to the cache when that succeeds.
+//
+// Outside frozen mode a failed checkout leaves HEAD in place and returns
+// ErrCheckoutFailed; any other error removes the clone.
+func (p *Project) cloneLocked(moduleName, repoURL, commitHash, requestedVersion, cloneDir string) error {
+	if err := p.git.Clone(repoURL, cloneDir, false); err != nil {
+		// A clone killed by git_timeout leaves a partial checkout behind.
+		os.RemoveAll(cloneDir)
+		return fmt.Errorf("%w: error cloning %s: %v", ErrCloneFailed, repoURL, err)
+	}
+	if commitHash == "" {
+		return nil
+	}
+
+	err := p.git.Checkout(cloneDir, commitHash)
+	if err == nil && p.Frozen() && p.git.CommitHash(cloneDir) != commitHash {
+		err = fmt.Errorf("checked out %s instead", p.git.CommitHash(cloneDir))
+	}
+
+	if err != nil && p.Frozen() {
+		os.RemoveAll(cloneDir)
+		return fmt.Errorf("%w: could not checkout commit %s, refusing to install another commit in frozen mode", ErrCheckoutFailed, commitHash)
+	} else if err != nil {
+		p.logf("Warning: Could not checkout commit %s for %s", commitHash, moduleName)
+		return fmt.Errorf("%w: could not checkout commit %s, restored HEAD instead", ErrCheckoutFailed, commitHash)
+	}
 
-		fmt.Printf("Restored %s to %s\n", config.Name, targetDir)
+	if checkedOut := p.git.CommitHash(cloneDir); checkedOut == commitHash {
+		p.cacheModule(repoURL, checkedOut, cloneDir, requestedVersion)
 	}
+	return nil
 }
@@ -0,0 +1,72 @@
+package modules
+
+import (
+	"github.com/acidlang/ace/archive"
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/license"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/sbom"
+	"github.com/acidlang/ace/source"
+)
+
+// Describe the project and every module in the lockfile for a software bill
+// of materials, from acid.lock and each module's module.acidcfg.
+//
+// Content hashes come from the lockfile for archives and from the installed
+// files otherwise, so modules should be restored first; missing ones are
+// listed without a hash.
+func (p *Project) SBOM() (sbom.Document, error) {
+	lockFile, err := p.readLock()
+	if err != nil {
+		return sbom.Document{}, err
+	}
+
+	doc := sbom.Document{Root: sbom.Component{Name: p.name()}}
+	if config, err := p.Config(); err == nil {
+		doc.Root.Version = config.Version
+		doc.Root.Author = config.Author
+		doc.Root.License = config.License
+	}
+	if doc.Root.License == "" {
+		doc.Root.License, _ = license.Detect(p.Root)
+	}
+
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		entry := lockFile[moduleName]
+		doc.Components = append(doc.Components, p.sbomComponent(p.module(moduleName, entry), entry))
+	}
+	return doc, nil
+}
+
+func (p *Project) sbomComponent(module Module, entry lock.LockEntry) sbom.Component {
+	component := sbom.Component{
+		Name:     module.Name,
+		Version:  lockedVersion(entry),
+		Commit:   entry.CommitHash,
+		Tags:     entry.Tags,
+		Checksum: entry.Checksum,
+	}
+	if component.Version == "" {
+		component.Version = entry.RequestedVersion
+	}
+
+	// A local replacement has no location anyone else could fetch it from.
+	if repo := replacedSource(entry); !(entry.Replace != "" && p.IsLocalReplacement(entry.Replace)) {
+		component.Repo = source.StripCredentials(repo)
+		component.Archive = archive.IsArchive(repo)
+	}
+
+	if module.Config != nil {
+		component.Author = module.Config.Author
+	}
+	component.License, _ = detectLicense(module.Dir)
+
+	if component.Checksum == "" {
+		if sum, err := checksum.Dir(module.Dir); err == nil {
+			component.Checksum = sum
+		} else {
+			p.logf("Warning: %s is not installed, listing it without a content hash", module.Name)
+		}
+	}
+	return component
+}
@@ -0,0 +1,105 @@
+package modules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/acidlang/ace/git"
+)
+
+// Get the keys allowed to sign each module's tags or commits, by module
+// name: the manifest's signers field merged with Options.Signers.
+//
+// A key is a GPG fingerprint or long key ID, an SSH public key in
+// authorized_keys format, or an SSH "SHA256:..." fingerprint.
+func (p *Project) Signers() map[string][]string {
+	signers := make(map[string][]string)
+	if config, err := p.Config(); err == nil {
+		for moduleName, keys := range config.Signers {
+			signers[moduleName] = append(signers[moduleName], keys...)
+		}
+	}
+	for moduleName, keys := range p.options.Signers {
+		signers[moduleName] = append(signers[moduleName], keys...)
+	}
+	return signers
+}
+
+// Check whether a module must be signed, in which case it is always cloned:
+// signatures live in git metadata, which proxies do not serve.
+func (p *Project) requiresSignature(moduleName string) bool {
+	return len(p.Signers()[moduleName]) > 0
+}
+
+// Verify that the clone in dir, checked out at version, is signed by a key
+// the project allows for moduleName. The tag is checked when version is one
+// pointing at the checked out commit, then the commit itself.
+//
+// Returns the fingerprint of the signing key, or "" when the module requires
+// no signature.
+func (p *Project) verifySignature(moduleName, dir, version string) (string, error) {
+	allowed := p.Signers()[moduleName]
+	if len(allowed) == 0 {
+		return "", nil
+	}
+
+	var sshKeys []string
+	for _, key := range allowed {
+		if _, ok := git.SSHFingerprint(key); ok {
+			sshKeys = append(sshKeys, key)
+		}
+	}
+
+	revs := []string{"HEAD"}
+	if version != "" && slices.Contains(p.git.Tags(dir), version) {
+		revs = []string{version, "HEAD"}
+	}
+
+	var failures []string
+	for _, rev := range revs {
+		signature, err := p.git.VerifySignature(dir, rev, sshKeys)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if !allowedSigner(signature, allowed) {
+			failures = append(failures, fmt.Sprintf("%s is signed by %s, which is not an allowed key", rev, signature.Key))
+			continue
+		}
+
+		signer := signature.Key
+		if signature.Signer != "" {
+			signer = signature.Signer + " (" + signature.Key + ")"
+		}
+		p.logf("Verified %s signature of %s by %s", signature.Format, moduleName, signer)
+		return signature.Key, nil
+	}
+	return "", fmt.Errorf("%w: %s must be signed by an allowed key: %s", ErrVerificationFailed, moduleName, strings.Join(failures, "; "))
+}
+
+// Check a signature's key against the allowed keys. GPG keys match by full
+// fingerprint or a long key ID suffix of the signing key or its primary key.
+func allowedSigner(signature git.Signature, allowed []string) bool {
+	for _, key := range allowed {
+		if fingerprint, ok := git.SSHFingerprint(key); ok {
+			key = fingerprint
+		}
+
+		if signature.Format == "ssh" {
+			if key == signature.Key {
+				return true
+			}
+			continue
+		}
+
+		key = strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(key, " ", ""), "0x"))
+		if len(key) < 16 {
+			continue
+		}
+		if strings.HasSuffix(strings.ToUpper(signature.Key), key) || signature.PrimaryKey != "" && strings.HasSuffix(strings.ToUpper(signature.PrimaryKey), key) {
+			return true
+		}
+	}
+	return false
+}
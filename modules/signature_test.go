@@ -0,0 +1,116 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/acidlang/ace/git"
+)
+
+func TestSigners(t *testing.T) {
+	p := newTestProject(t, Options{Signers: map[string][]string{
+		"json": {"SHA256:option"},
+		"http": {"0123456789ABCDEF"},
+	}})
+	writeTestFile(t, p.ManifestPath(), `{"name": "app", "version": "0.1.0", "signers": {"json": ["SHA256:manifest"]}}`)
+
+	want := map[string][]string{
+		"json": {"SHA256:manifest", "SHA256:option"},
+		"http": {"0123456789ABCDEF"},
+	}
+	if got := p.Signers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Signers = %v, want %v", got, want)
+	}
+	if !p.requiresSignature("json") || p.requiresSignature("yaml") {
+		t.Errorf("requiresSignature(json, yaml) = %v, %v", p.requiresSignature("json"), p.requiresSignature("yaml"))
+	}
+}
+
+func TestAllowedSigner(t *testing.T) {
+	gpg := git.Signature{Format: "gpg", Key: "BBBB00000000000000000000AAAA0123456789ABCDEF", PrimaryKey: "CCCC00000000000000000000CCCC0123456789ABCDEF"}
+	ssh := git.Signature{Format: "ssh", Key: "SHA256:abc"}
+
+	tests := []struct {
+		name      string
+		signature git.Signature
+		allowed   []string
+		want      bool
+	}{
+		{"full fingerprint", gpg, []string{gpg.Key}, true},
+		{"long key ID", gpg, []string{"0xaaaa0123456789abcdef"}, true},
+		{"spaced fingerprint", gpg, []string{"AAAA 0123 4567 89AB CDEF"}, true},
+		{"primary key", gpg, []string{"CCCC0123456789ABCDEF"}, true},
+		{"short key ID", gpg, []string{"89ABCDEF"}, false},
+		{"other gpg key", gpg, []string{"DDDD0123456789ABCDEF"}, false},
+		{"ssh fingerprint", ssh, []string{"SHA256:abc"}, true},
+		{"other ssh fingerprint", ssh, []string{"SHA256:abd"}, false},
+		{"gpg key for ssh", ssh, []string{gpg.Key}, false},
+	}
+	for _, test := range tests {
+		if got := allowedSigner(test.signature, test.allowed); got != test.want {
+			t.Errorf("%s: allowedSigner = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInstallRequiresSignature(t *testing.T) {
+	gitEnv(t)
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	keys := t.TempDir()
+	var public []string
+	for _, name := range []string{"ada", "eve"} {
+		path := filepath.Join(keys, name)
+		if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", path).CombinedOutput(); err != nil {
+			t.Fatalf("ssh-keygen: %v\n%s", err, output)
+		}
+		content, err := os.ReadFile(path + ".pub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		public = append(public, strings.TrimSpace(string(content)))
+	}
+
+	repo := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	commitVersion(t, repo, "json", "v1.1.0")
+	runGit(t, repo, "-c", "gpg.format=ssh", "-c", "user.signingkey="+filepath.Join(keys, "ada"), "tag", "-s", "-m", "v1.1.0", "v1.1.0")
+	fingerprint, _ := git.SSHFingerprint(public[0])
+
+	tests := []struct {
+		name    string
+		version string
+		allowed string
+		signer  string
+	}{
+		{"signed tag", "v1.1.0", public[0], fingerprint},
+		{"signed by another key", "v1.1.0", public[1], ""},
+		{"unsigned tag", "v1.0.0", public[0], ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestProject(t, Options{Signers: map[string][]string{"json": {test.allowed}}})
+			_, err := p.Install(repo, test.version)
+			if test.signer == "" {
+				if !errors.Is(err, ErrVerificationFailed) {
+					t.Errorf("Install = %v, want ErrVerificationFailed", err)
+				}
+				if _, err := os.Stat(p.ModuleDir("json")); err == nil {
+					t.Errorf("an unverified module was installed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Install: %v", err)
+			}
+			if entry := readLock(t, p)["json"]; entry.Signer != test.signer {
+				t.Errorf("locked signer %q, want %q", entry.Signer, test.signer)
+			}
+		})
+	}
+}
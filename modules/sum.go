@@ -0,0 +1,78 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/acidlang/ace/checksum"
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/source"
+)
+
+// Path of the checksum database, acid.sum next to the lockfile.
+func (p *Project) SumPath() string {
+	return filepath.Join(filepath.Dir(p.LockPath()), "acid.sum")
+}
+
+// Check the module in dir, fetched from repoURL at commitHash, against
+// acid.sum, recording its content hash on first use.
+//
+// versions are the tags it was fetched by, which are checked and recorded
+// too: a tag that was moved upstream then no longer matches. Local
+// repositories have no stable identity across machines and are skipped.
+func (p *Project) verifySum(repoURL, dir, commitHash string, versions ...string) error {
+	if commitHash == "" || !source.IsRemote(repoURL) {
+		return nil
+	}
+
+	sum, err := checksum.Dir(dir)
+	if err != nil {
+		return fmt.Errorf("could not checksum %s: %v", dir, err)
+	}
+
+	p.lockMu.Lock()
+	defer p.lockMu.Unlock()
+
+	sums, err := lock.ParseSumFile(p.SumPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	module := lock.SumModule(repoURL)
+	added := false
+	for _, version := range append(versions, commitHash) {
+		key := lock.SumKey{Module: module, Version: version}
+		recorded, known := sums[key]
+		if !known {
+			sums[key] = sum
+			added = true
+			continue
+		}
+		if !checksum.Equal(recorded, sum) {
+			return fmt.Errorf("%w: %s@%s now has content %s, but acid.sum recorded %s when it was first installed.\n"+
+				"The upstream tag was moved or its history rewritten. Check the change with the module's authors; to trust\n"+
+				"the new content, delete the line from %s and install again", ErrVerificationFailed, module, version, sum, recorded, p.SumPath())
+		}
+	}
+
+	// Frozen mode checks what acid.sum records but never adds to it.
+	if !added || p.Frozen() {
+		return nil
+	}
+	if err := lock.WriteSumFile(p.SumPath(), sums); err != nil {
+		return fmt.Errorf("error updating acid.sum: %w", err)
+	}
+	return nil
+}
+
+// Get the versions of a lock entry to check in acid.sum: its requested
+// version when that is one of its tags. Branches move, so they are not.
+func sumVersions(entry lock.LockEntry) []string {
+	for _, tag := range entry.Tags {
+		if tag == entry.RequestedVersion {
+			return []string{tag}
+		}
+	}
+	return nil
+}
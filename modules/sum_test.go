@@ -0,0 +1,97 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/source"
+)
+
+// Serve the repositories in remotes as https://example.com/acid/<name>, so
+// that acid.sum treats them as remote modules.
+func remoteOptions(remotes string) Options {
+	return Options{Rewrites: source.Rewrites{"https://example.com/acid/": remotes + string(filepath.Separator)}}
+}
+
+func TestSumRecordsAndVerifies(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+	repo := newRepo(t, remotes, "json", "v1.0.0")
+	commit := runGit(t, repo, "rev-parse", "v1.0.0")
+	p := newTestProject(t, remoteOptions(remotes))
+
+	if _, err := p.Install("https://example.com/acid/json", "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	sums, err := lock.ParseSumFile(p.SumPath())
+	if err != nil {
+		t.Fatalf("ParseSumFile: %v", err)
+	}
+	tagSum, tagged := sums[lock.SumKey{Module: "example.com/acid/json", Version: "v1.0.0"}]
+	commitSum, committed := sums[lock.SumKey{Module: "example.com/acid/json", Version: commit}]
+	if len(sums) != 2 || !tagged || !committed || tagSum != commitSum {
+		t.Fatalf("acid.sum = %v, want the tag and commit with one hash", sums)
+	}
+
+	// Move the tag upstream to different content.
+	commitVersion(t, repo, "json", "v1.0.1")
+	runGit(t, repo, "tag", "-f", "v1.0.0")
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"install of the moved tag", func() error {
+			_, err := p.Install("https://example.com/acid/json", "v1.0.0")
+			return err
+		}, ErrVerificationFailed},
+		{"restore of the locked commit", func() error {
+			os.RemoveAll(p.PkgDir())
+			_, err := p.Restore()
+			return err
+		}, nil},
+	}
+	for _, test := range tests {
+		if err := test.run(); !errors.Is(err, test.want) {
+			t.Errorf("%s = %v, want %v", test.name, err, test.want)
+		}
+		if got := installedVersion(t, p, "json"); got != "v1.0.0" {
+			t.Errorf("after the %s json is %s, want the original v1.0.0", test.name, got)
+		}
+		if after, _ := lock.ParseSumFile(p.SumPath()); len(after) != 2 {
+			t.Errorf("after the %s acid.sum = %v", test.name, after)
+		}
+	}
+}
+
+func TestSumSkipsLocalAndFrozen(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+	repo := newRepo(t, remotes, "json", "v1.0.0")
+
+	p := newTestProject(t, remoteOptions(remotes))
+	if _, err := p.Install(repo, "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if _, err := os.Stat(p.SumPath()); !os.IsNotExist(err) {
+		t.Errorf("installing a local repository wrote %s", p.SumPath())
+	}
+
+	if _, err := p.Install("https://example.com/acid/json", "v1.0.0"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	os.Remove(p.SumPath())
+	os.RemoveAll(p.PkgDir())
+	options := remoteOptions(remotes)
+	options.Frozen = true
+	if _, err := NewProject(p.Root, options).Restore(); err != nil {
+		t.Fatalf("frozen Restore: %v", err)
+	}
+	if _, err := os.Stat(p.SumPath()); !os.IsNotExist(err) {
+		t.Errorf("a frozen restore wrote %s", p.SumPath())
+	}
+}
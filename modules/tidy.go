@@ -0,0 +1,213 @@
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/acidlang/ace/lock"
+	"github.com/acidlang/ace/registry"
+)
+
+// Matches `import json`, `import "json"` and `import "json/encoding"`.
+var importPattern = regexp.MustCompile(`^\s*import\s+"?([A-Za-z0-9_.\-/]+)"?`)
+
+// The difference between what a project imports and what it has installed.
+type TidyReport struct {
+	// Installed modules nothing imports, directly or through another module.
+	Unused []string
+	// Imported modules that are not installed but resolve through a registry.
+	Missing []string
+	// Imported names that are neither installed, local nor in a registry,
+	// e.g. standard library imports.
+	Unresolved []string
+	// Replace directives in module.acidcfg for modules that are not used.
+	UnusedReplacements []string
+}
+
+// Check whether the project has nothing to prune or install.
+func (report TidyReport) Tidy() bool {
+	return len(report.Unused) == 0 && len(report.Missing) == 0
+}
+
+// Compare the imports in the project's Acid sources against the lockfile.
+//
+// Modules imported only by other installed modules count as used. Fails when
+// the sources cannot all be read, as nothing could be said to be unused.
+func (p *Project) CheckTidy() (TidyReport, error) {
+	var report TidyReport
+
+	lockFile := p.lockFile()
+
+	used := make(map[string]bool)
+	notInstalled := make(map[string]bool)
+
+	pending, err := p.scanImports(p.Root)
+	if err != nil {
+		return report, err
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if used[name] || notInstalled[name] {
+			continue
+		}
+
+		if _, installed := lockFile[name]; !installed {
+			notInstalled[name] = true
+			continue
+		}
+
+		used[name] = true
+		imports, err := p.scanImports(p.ModuleDir(name))
+		if err != nil {
+			return report, err
+		}
+		pending = append(pending, imports...)
+	}
+
+	for _, moduleName := range sortedModuleNames(lockFile) {
+		if !used[moduleName] {
+			report.Unused = append(report.Unused, moduleName)
+		}
+	}
+
+	for name := range notInstalled {
+		if p.resolvable(name) {
+			report.Missing = append(report.Missing, name)
+		} else {
+			report.Unresolved = append(report.Unresolved, name)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unresolved)
+
+	for name := range p.Replacements() {
+		if !used[name] && !notInstalled[name] {
+			report.UnusedReplacements = append(report.UnusedReplacements, name)
+		}
+	}
+	sort.Strings(report.UnusedReplacements)
+
+	return report, nil
+}
+
+// Remove unused modules and install missing ones.
+//
+// Returns the report the changes were based on, and an *OperationError
+// listing the modules that could not be removed or installed.
+func (p *Project) Tidy() (TidyReport, error) {
+	report, err := p.CheckTidy()
+	if err != nil || report.Tidy() {
+		return report, err
+	}
+	if p.Frozen() {
+		return report, lock.ErrFrozen
+	}
+
+	summary := failureSummary{operation: "tidy"}
+	for _, name := range report.Unused {
+		summary.add(name, p.Remove(name))
+	}
+	for _, name := range report.Missing {
+		_, err := p.Install(name, "")
+		if err != nil {
+			p.logf("Error installing %s: %v", name, err)
+		}
+		summary.add(name, err)
+	}
+	return report, summary.err()
+}
+
+// Collect the module names imported by the Acid sources under dir.
+//
+// Imports of files or directories that exist next to the importing file, and
+// relative imports, refer to the project itself and are skipped.
+//
+// dir is resolved first, as local replacements and workspace members are
+// linked into pkg/ and filepath.Walk does not follow links. A module that is
+// not installed has no imports.
+func (p *Project) scanImports(dir string) ([]string, error) {
+	var imports []string
+
+	dir, err := filepath.EvalSymlinks(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	pkgDir, err := filepath.EvalSymlinks(p.PkgDir())
+	if err != nil {
+		pkgDir = p.PkgDir()
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path != dir && (name == "pkg" || path == pkgDir || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "tmp_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".acid" {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			// A dangling link.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			match := importPattern.FindStringSubmatch(scanner.Text())
+			if match == nil || strings.HasPrefix(match[1], ".") {
+				continue
+			}
+
+			moduleName := strings.SplitN(match[1], "/", 2)[0]
+			if isLocalImport(filepath.Dir(path), dir, moduleName) {
+				continue
+			}
+			imports = append(imports, moduleName)
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning imports in %s: %v", dir, err)
+	}
+
+	return imports, nil
+}
+
+func (p *Project) resolvable(name string) bool {
+	if !registry.IsShortName(name) || len(p.registry.Registries()) == 0 {
+		return false
+	}
+	_, err := p.registry.Resolve(name)
+	return err == nil
+}
+
+func isLocalImport(fileDir, rootDir, name string) bool {
+	for _, base := range []string{fileDir, rootDir} {
+		if _, err := os.Stat(filepath.Join(base, name+".acid")); err == nil {
+			return true
+		}
+		if info, err := os.Stat(filepath.Join(base, name)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
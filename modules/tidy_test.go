@@ -0,0 +1,76 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTidy(t *testing.T) {
+	gitEnv(t)
+	remotes := t.TempDir()
+
+	// json imports base, so base is used although the project never imports it.
+	json := newRepo(t, remotes, "json", "v1.0.0")
+	writeTestFile(t, filepath.Join(json, "decode.acid"), "import base\n")
+	runGit(t, json, "add", "-A")
+	runGit(t, json, "commit", "-q", "-m", "import base")
+	base := newRepo(t, remotes, "base", "v1.0.0")
+	yaml := newRepo(t, remotes, "yaml", "v1.0.0")
+	http := newRepo(t, remotes, "http", "v1.0.0")
+
+	registryDir := t.TempDir()
+	writeTestFile(t, filepath.Join(registryDir, "index.json"), fmt.Sprintf(
+		`{"http": {"repo": %q, "versions": [{"version": "v1.0.0", "commit": %q}]}}`,
+		http, runGit(t, http, "rev-parse", "v1.0.0")))
+
+	p := newTestProject(t, Options{Registries: []string{registryDir}})
+	for _, repo := range []string{json, base, yaml} {
+		if _, err := p.Install(repo, ""); err != nil {
+			t.Fatalf("Install %s: %v", repo, err)
+		}
+	}
+	writeTestFile(t, filepath.Join(p.Root, "main.acid"), "import json\nimport \"http/client\"\nimport io\nimport util\nimport \"./local\"\n")
+	writeTestFile(t, filepath.Join(p.Root, "util.acid"), "")
+
+	tests := []struct {
+		name string
+		want TidyReport
+	}{
+		{"before tidy", TidyReport{Unused: []string{"yaml"}, Missing: []string{"http"}, Unresolved: []string{"io"}}},
+		{"after tidy", TidyReport{Unresolved: []string{"io"}}},
+	}
+	for i, test := range tests {
+		if i > 0 {
+			if _, err := p.Tidy(); err != nil {
+				t.Fatalf("Tidy: %v", err)
+			}
+		}
+		report, err := p.CheckTidy()
+		if err != nil {
+			t.Fatalf("%s: CheckTidy: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(report, test.want) {
+			t.Errorf("%s: CheckTidy = %+v, want %+v", test.name, report, test.want)
+		}
+		if report.Tidy() != (i > 0) {
+			t.Errorf("%s: Tidy() = %v", test.name, report.Tidy())
+		}
+	}
+
+	if _, err := os.Stat(p.ModuleDir("yaml")); err == nil {
+		t.Errorf("Tidy left the unused yaml installed")
+	}
+	if got := installedVersion(t, p, "http"); got != "v1.0.0" {
+		t.Errorf("Tidy installed http %s, want the published v1.0.0", got)
+	}
+}
@@ -0,0 +1,320 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/acidlang/ace/cmds"
+	"github.com/acidlang/ace/lock"
+)
+
+// A workspace of modules developed side by side, declared in an ace.work file:
+//
+//	{
+//	  "members": ["./core", "./http"]
+//	}
+type Workspace struct {
+	Root    string
+	Members []string
+}
+
+// Parse a workspace file, resolving member directories relative to it.
+func ParseWorkspaceFile(filename string) (Workspace, error) {
+	var workspace Workspace
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return workspace, err
+	}
+
+	workspace.Root, err = filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return workspace, err
+	}
+
+	var file struct {
+		Members []string `json:"members"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return workspace, fmt.Errorf("invalid JSON in %s: %v", filename, err)
+	}
+
+	for _, member := range file.Members {
+		if member = strings.TrimSpace(member); member != "" {
+			workspace.Members = append(workspace.Members, filepath.Join(workspace.Root, member))
+		}
+	}
+
+	if len(workspace.Members) == 0 {
+		return workspace, fmt.Errorf("no members listed in %s", filename)
+	}
+	return workspace, nil
+}
+
+// Find the ace.work file in the project root, if there is one.
+func (p *Project) FindWorkspace() (Workspace, bool, error) {
+	filename := p.resolve("ace.work")
+	if !cmds.FileExists(filename) {
+		return Workspace{}, false, nil
+	}
+
+	workspace, err := ParseWorkspaceFile(filename)
+	if err != nil {
+		return workspace, true, fmt.Errorf("%w: error parsing ace.work: %v", ErrInvalidConfig, err)
+	}
+	return workspace, true, nil
+}
+
+// Restore every workspace member from its lockfile.
+//
+// Members depending on each other are linked locally, and a dependency shared
+// by several members is cloned once and copied into the others.
+func (p *Project) RestoreWorkspace(workspace Workspace) error {
+	members := p.memberModules(workspace)
+	if p.Frozen() {
+		if err := p.checkWorkspaceFrozen(workspace, members); err != nil {
+			return err
+		}
+	}
+
+	shared, err := p.sharedEntries(workspace, members)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]string)
+	summary := failureSummary{operation: "workspace restore"}
+
+	p.forEachMember(workspace, func(name string, member *Project, lockFile lock.LockFile) {
+		replacements := member.Replacements()
+
+		for _, moduleName := range sortedModuleNames(lockFile) {
+			entry := lockFile[moduleName]
+
+			var err error
+			if memberDir, ok := members[moduleName]; ok {
+				err = member.linkMember(moduleName, memberDir)
+			} else if _, ok := replacements[moduleName]; ok {
+				_, err = member.restoreModule(moduleName, entry, replacements)
+			} else if sourceDir, ok := resolved[moduleName]; ok {
+				err = member.shareModule(moduleName, sourceDir, entry, shared[moduleName])
+			} else {
+				var module Module
+				module, err = member.restoreModule(moduleName, shared[moduleName], replacements)
+				if module.Dir != "" {
+					resolved[moduleName], _ = filepath.Abs(module.Dir)
+					if syncErr := member.syncLockEntry(moduleName, entry, shared[moduleName]); err == nil {
+						err = syncErr
+					}
+				}
+			}
+
+			if err != nil {
+				p.logf("Error: %v", err)
+			}
+			summary.add(name+"/"+moduleName, err)
+		}
+	})
+	return summary.err()
+}
+
+// Upgrade every workspace member, fetching each shared dependency only once.
+func (p *Project) UpgradeWorkspace(workspace Workspace) error {
+	if p.Frozen() {
+		return lock.ErrFrozen
+	}
+
+	members := p.memberModules(workspace)
+	upgraded := make(map[string]Upgrade)
+	summary := failureSummary{operation: "workspace upgrade"}
+
+	p.forEachMember(workspace, func(name string, member *Project, lockFile lock.LockFile) {
+		replacements := member.Replacements()
+
+		for _, moduleName := range sortedModuleNames(lockFile) {
+			entry := lockFile[moduleName]
+
+			var err error
+			if memberDir, ok := members[moduleName]; ok {
+				err = member.linkMember(moduleName, memberDir)
+			} else if _, ok := replacements[moduleName]; ok {
+				_, err = member.upgradeModule(moduleName, entry, replacements)
+			} else if upgrade, ok := upgraded[moduleName]; ok {
+				err = member.shareModule(moduleName, upgrade.Dir, entry, upgrade.LockEntry)
+			} else {
+				var upgrade Upgrade
+				if upgrade, err = member.upgradeModule(moduleName, entry, replacements); err == nil {
+					upgrade.Dir, _ = filepath.Abs(upgrade.Dir)
+					upgraded[moduleName] = upgrade
+				}
+			}
+
+			if err != nil {
+				p.logf("  Error: %v", err)
+			}
+			summary.add(name+"/"+moduleName, err)
+		}
+	})
+	return summary.err()
+}
+
+// Check that every member's lockfile matches that member exactly. Entries
+// for other members are linked locally, so they need not be pinned.
+func (p *Project) checkWorkspaceFrozen(workspace Workspace, members map[string]string) error {
+	var problems []string
+	for _, memberDir := range workspace.Members {
+		member := p.member(memberDir)
+		lockFile, err := lock.ParseLockFile(member.LockPath())
+		if err != nil {
+			continue
+		}
+
+		for moduleName := range members {
+			delete(lockFile, moduleName)
+		}
+		for _, problem := range member.CheckFrozen(lockFile) {
+			problems = append(problems, fmt.Sprintf("%s: %s", workspace.relative(memberDir), problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return frozenError(problems)
+	}
+	return nil
+}
+
+// Run fn for each member with its lockfile, skipping members without one.
+func (p *Project) forEachMember(workspace Workspace, fn func(name string, member *Project, lockFile lock.LockFile)) {
+	for _, memberDir := range workspace.Members {
+		name := workspace.relative(memberDir)
+		p.logf("Workspace member %s", name)
+
+		member := p.member(memberDir)
+		lockFile, err := lock.ParseLockFile(member.LockPath())
+		if err != nil {
+			p.logf("No %s found, skipping.", member.lockName)
+			continue
+		}
+		fn(name, member, lockFile)
+	}
+}
+
+// Open a workspace member as a project sharing this project's options.
+func (p *Project) member(memberDir string) *Project {
+	options := p.options
+	options.Git = p.git
+	options.Logger = p.logger
+	return NewProject(memberDir, options)
+}
+
+// Map each member's declared module name to its directory.
+func (p *Project) memberModules(workspace Workspace) map[string]string {
+	members := make(map[string]string)
+	for _, memberDir := range workspace.Members {
+		config, err := p.member(memberDir).Config()
+		if err != nil {
+			p.logf("Warning: workspace member %s has no readable module.acidcfg", workspace.relative(memberDir))
+			continue
+		}
+		members[config.Name] = memberDir
+	}
+	return members
+}
+
+// Pick one lock entry per external dependency across all members.
+//
+// When members lock different commits, the most recently installed one wins.
+func (p *Project) sharedEntries(workspace Workspace, members map[string]string) (map[string]lock.LockEntry, error) {
+	shared := make(map[string]lock.LockEntry)
+	for _, memberDir := range workspace.Members {
+		lockFile, err := lock.ParseLockFile(p.member(memberDir).LockPath())
+		if err != nil {
+			continue
+		}
+
+		for _, moduleName := range sortedModuleNames(lockFile) {
+			entry := lockFile[moduleName]
+			if _, ok := members[moduleName]; ok {
+				continue
+			}
+
+			existing, ok := shared[moduleName]
+			if !ok {
+				shared[moduleName] = entry
+				continue
+			}
+
+			if existing.CommitHash != entry.CommitHash || existing.Checksum != entry.Checksum || !p.options.Rewrites.Same(existing.Repo, entry.Repo) {
+				if p.Frozen() {
+					return nil, fmt.Errorf("%w: workspace members lock different versions of %s, refusing to unify them in frozen mode", ErrVerificationFailed, moduleName)
+				}
+				if entry.Timestamp > existing.Timestamp {
+					existing = entry
+					shared[moduleName] = entry
+				}
+				p.logf("Note: workspace members lock different versions of %s, using %s", moduleName, describeEntry(existing))
+			}
+		}
+	}
+	return shared, nil
+}
+
+func (workspace Workspace) relative(dir string) string {
+	if rel, err := filepath.Rel(workspace.Root, dir); err == nil {
+		return rel
+	}
+	return dir
+}
+
+func (p *Project) linkMember(moduleName, memberDir string) error {
+	targetDir := p.ModuleDir(moduleName)
+	if err := p.linkLocalModule(memberDir, targetDir); err != nil {
+		return fmt.Errorf("error linking workspace member %s: %v", moduleName, err)
+	}
+	p.logf("Linked workspace member %s to %s", moduleName, targetDir)
+	return nil
+}
+
+// Copy a dependency already resolved for another member into this one.
+func (p *Project) shareModule(moduleName, sourceDir string, current, resolved lock.LockEntry) error {
+	targetDir := p.ModuleDir(filepath.Base(sourceDir))
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(targetDir), err)
+	}
+
+	newDir := filepath.Join(filepath.Dir(targetDir), ".new_"+filepath.Base(targetDir))
+	os.RemoveAll(newDir)
+	if err := copyDir(sourceDir, newDir); err != nil {
+		os.RemoveAll(newDir)
+		return fmt.Errorf("error copying %s to %s: %v", sourceDir, targetDir, err)
+	}
+	if err := p.moveInto(newDir, targetDir); err != nil {
+		return err
+	}
+
+	p.logf("Reused %s for %s", describeEntry(resolved), targetDir)
+	return p.syncLockEntry(moduleName, current, resolved)
+}
+
+// Point this member's lock entry at the version resolved for the workspace.
+func (p *Project) syncLockEntry(moduleName string, current, resolved lock.LockEntry) error {
+	if p.options.Rewrites.Same(current.Repo, resolved.Repo) && current.CommitHash == resolved.CommitHash && current.Checksum == resolved.Checksum && current.Replace == resolved.Replace {
+		return nil
+	}
+	return p.updateLock(moduleName, resolved)
+}
+
+func describeEntry(entry lock.LockEntry) string {
+	if entry.RequestedVersion != "" {
+		return entry.RequestedVersion
+	}
+	if len(entry.CommitHash) >= 7 {
+		return entry.CommitHash[:7]
+	}
+	if entry.Checksum != "" {
+		return shortChecksum(entry.Checksum)
+	}
+	return "latest"
+}
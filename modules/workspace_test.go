@@ -0,0 +1,145 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/acidlang/ace/lock"
+)
+
+func TestParseWorkspaceFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		members []string
+		valid   bool
+	}{
+		{"members", `{"members": ["./core", "http", " "]}`, []string{"core", "http"}, true},
+		{"no members", `{"members": []}`, nil, false},
+		{"invalid JSON", `{"members": ["core"`, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			filename := filepath.Join(root, "ace.work")
+			writeTestFile(t, filename, test.content)
+
+			workspace, err := ParseWorkspaceFile(filename)
+			if (err == nil) != test.valid {
+				t.Fatalf("ParseWorkspaceFile = %v, want valid %v", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+			var members []string
+			for _, member := range workspace.Members {
+				members = append(members, workspace.relative(member))
+			}
+			if !reflect.DeepEqual(members, test.members) {
+				t.Errorf("members = %v, want %v", members, test.members)
+			}
+		})
+	}
+}
+
+// Create a workspace whose members core and http both depend on json at
+// v1.0.0, with http also depending on core.
+func newTestWorkspace(t *testing.T, json string) (*Project, Workspace) {
+	t.Helper()
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "ace.work"), `{"members": ["core", "http"]}`)
+
+	for _, name := range []string{"core", "http"} {
+		dir := filepath.Join(root, name)
+		os.MkdirAll(dir, 0755)
+		writeTestFile(t, filepath.Join(dir, "module.acidcfg"), `{"name": "`+name+`", "version": "0.1.0"}`)
+		if _, err := NewProject(dir, Options{}).Install(json, "v1.0.0"); err != nil {
+			t.Fatalf("installing json into %s: %v", name, err)
+		}
+	}
+	http := NewProject(filepath.Join(root, "http"), Options{})
+	if err := http.updateLock("core", lock.LockEntry{Repo: "https://example.com/acid/core"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProject(root, Options{})
+	workspace, found, err := p.FindWorkspace()
+	if !found || err != nil {
+		t.Fatalf("FindWorkspace = %v, %v", found, err)
+	}
+	return p, workspace
+}
+
+func TestRestoreWorkspace(t *testing.T) {
+	gitEnv(t)
+	json := newRepo(t, t.TempDir(), "json", "v1.0.0", "v1.1.0")
+
+	tests := []struct {
+		name string
+		// Lock a newer json in core than in http.
+		diverge bool
+		want    string
+	}{
+		{"shared version", false, "v1.0.0"},
+		{"most recently locked version wins", true, "v1.1.0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, workspace := newTestWorkspace(t, json)
+			core, http := p.member(workspace.Members[0]), p.member(workspace.Members[1])
+			if test.diverge {
+				lockFile := readLock(t, core)
+				entry := lockFile["json"]
+				entry.CommitHash = runGit(t, json, "rev-parse", "v1.1.0")
+				entry.RequestedVersion = "v1.1.0"
+				entry.Timestamp = "2999-01-01T00:00:00"
+				lockFile["json"] = entry
+				if err := lock.WriteLockFile(core.LockPath(), lockFile); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, member := range []*Project{core, http} {
+				os.RemoveAll(member.PkgDir())
+			}
+
+			if err := p.RestoreWorkspace(workspace); err != nil {
+				t.Fatalf("RestoreWorkspace: %v", err)
+			}
+			for _, member := range []*Project{core, http} {
+				if got := installedVersion(t, member, "json"); got != test.want {
+					t.Errorf("%s has json %s, want %s", member.Root, got, test.want)
+				}
+				if entry := readLock(t, member)["json"]; entry.RequestedVersion != test.want {
+					t.Errorf("%s locks json %s, want %s", member.Root, entry.RequestedVersion, test.want)
+				}
+			}
+
+			target, err := filepath.EvalSymlinks(http.ModuleDir("core"))
+			if want, _ := filepath.EvalSymlinks(core.Root); err != nil || target != want {
+				t.Errorf("http/pkg/core resolves to %s (%v), want the core member", target, err)
+			}
+		})
+	}
+}
+
+func TestUpgradeWorkspace(t *testing.T) {
+	gitEnv(t)
+	json := newRepo(t, t.TempDir(), "json", "v1.0.0")
+	p, workspace := newTestWorkspace(t, json)
+	latest := commitVersion(t, json, "json", "v1.1.0")
+
+	if err := p.UpgradeWorkspace(workspace); err != nil {
+		t.Fatalf("UpgradeWorkspace: %v", err)
+	}
+	for _, memberDir := range workspace.Members {
+		member := p.member(memberDir)
+		if got := installedVersion(t, member, "json"); got != "v1.1.0" {
+			t.Errorf("%s has json %s after upgrading", memberDir, got)
+		}
+		if entry := readLock(t, member)["json"]; entry.CommitHash != latest {
+			t.Errorf("%s locks json at %s, want %s", memberDir, entry.CommitHash, latest)
+		}
+	}
+}
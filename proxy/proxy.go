@@ -0,0 +1,210 @@
+// Package proxy implements the module proxy protocol, which serves fixed
+// versions of modules without git:
+//
+//	/<module>/@v/list            known versions, one per line
+//	/<module>/@v/<version>.info  {"version": ..., "commit": ..., "checksum": ...}
+//	/<module>/@v/<version>.zip   the module's files at that version
+//
+// <module> is the repository's canonical URL, e.g. github.com/acidlang/json,
+// and <version> a semantic version tag or full commit hash. A cache directory
+// laid out the same way can be served as is, or used directly through a
+// file:// URL.
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/acidlang/ace/archive"
+	"github.com/acidlang/ace/semver"
+	"github.com/acidlang/ace/source"
+)
+
+// A module version served by a proxy.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	// Content hash of the files in the zip, see checksum.Dir.
+	Checksum string `json:"checksum"`
+}
+
+// Reported when a proxy does not have the requested module version.
+var ErrNotFound = errors.New("not found on proxy")
+
+// Get the module path a repository is served under, or false for local
+// paths, which no proxy can serve.
+func ModulePath(repoURL string) (string, bool) {
+	if !source.IsRemote(repoURL) {
+		return "", false
+	}
+
+	module := filepath.ToSlash(source.Canonical(repoURL))
+	for segment := range strings.SplitSeq(module, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, "@") {
+			return "", false
+		}
+	}
+	return module, true
+}
+
+// Check that a version can appear in a proxy path.
+func validVersion(version string) bool {
+	return version != "" && !strings.ContainsAny(version, `/\`) && !strings.HasPrefix(version, ".")
+}
+
+// Check whether a version is pinned, a semantic version tag or a full commit
+// hash, and so can be served by a proxy. Anything else may be a branch,
+// which moves and would go stale in a proxy.
+func Pinned(version string) bool {
+	if semver.Valid(version) {
+		return true
+	}
+	if len(version) != 40 && len(version) != 64 {
+		return false
+	}
+	return strings.Trim(strings.ToLower(version), "0123456789abcdef") == ""
+}
+
+// Fetches module versions from proxies. The zero value lets requests take as
+// long as they need.
+type Client struct {
+	// How long a request or download may take, no limit when zero.
+	Timeout time.Duration
+}
+
+// Get a module version's info from the proxy at base, an http(s):// or
+// file:// URL.
+func (c Client) FetchInfo(base, module, version string) (Info, error) {
+	var info Info
+	if !validVersion(version) || !Pinned(version) {
+		return info, fmt.Errorf("%w: version %s", ErrNotFound, version)
+	}
+
+	content, err := c.get(versionURL(base, module, version) + ".info")
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return info, fmt.Errorf("invalid info for %s@%s: %v", module, version, err)
+	}
+	return info, nil
+}
+
+// Download a module version's zip from the proxy at base and extract it into
+// dir, which must not exist.
+func (c Client) Download(base, module, version, dir string) error {
+	return archive.Client{Timeout: c.Timeout}.Fetch(versionURL(base, module, version)+".zip", dir, "")
+}
+
+func versionURL(base, module, version string) string {
+	return strings.TrimSuffix(base, "/") + "/" + module + "/@v/" + version
+}
+
+func (c Client) get(url string) ([]byte, error) {
+	if path, isFile := strings.CutPrefix(url, "file://"); isFile {
+		content, err := os.ReadFile(filepath.FromSlash(path))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, url)
+		}
+		return content, err
+	}
+
+	client := http.Client{Timeout: c.Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.New(source.Redact(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, source.Redact(url))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GET %s: %s", source.Redact(url), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Add a module version to the cache directory dir, zipping the files in
+// moduleDir and listing the version.
+func Store(dir, module string, info Info, moduleDir string) error {
+	if !validVersion(info.Version) {
+		return fmt.Errorf("version %s cannot be cached", info.Version)
+	}
+
+	versionDir := filepath.Join(dir, filepath.FromSlash(module), "@v")
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return err
+	}
+
+	// Write the zip under a temporary name, so a reader never sees half of it.
+	zipFile, err := os.CreateTemp(versionDir, ".zip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(zipFile.Name())
+	err = archive.WriteZip(moduleDir, zipFile)
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(zipFile.Name(), filepath.Join(versionDir, info.Version+".zip")); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, info.Version+".info"), append(content, '\n'), 0644); err != nil {
+		return err
+	}
+
+	listPath := filepath.Join(versionDir, "list")
+	existing, _ := os.ReadFile(listPath)
+	versions := strings.Fields(string(existing))
+	if slices.Contains(versions, info.Version) {
+		return nil
+	}
+	versions = append(versions, info.Version)
+	return os.WriteFile(listPath, []byte(strings.Join(versions, "\n")+"\n"), 0644)
+}
+
+// Serve a cache directory over HTTP at addr, e.g. ":8080", in the proxy
+// format. Requests outside the /<module>/@v/ files get a 404.
+func Serve(dir, addr string, logf func(format string, args ...any)) error {
+	return http.ListenAndServe(addr, Handler(dir, logf))
+}
+
+// Get the handler Serve uses to serve a cache directory.
+func Handler(dir string, logf func(format string, args ...any)) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		_, file, found := strings.Cut(r.URL.Path, "/@v/")
+		if !found || file == "" || strings.Contains(file, "/") || strings.HasPrefix(file, ".") {
+			http.NotFound(w, r)
+			return
+		}
+
+		logf("%s %s", r.Method, r.URL.Path)
+		if file == "list" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,299 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acidlang/ace/semver"
+	"github.com/acidlang/ace/source"
+)
+
+// A published version of a package, with the commit and content checksum it resolves to.
+type Version struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit"`
+	Checksum string `json:"checksum"`
+}
+
+// A package listed in a registry index.
+type Package struct {
+	Repo        string    `json:"repo"`
+	Description string    `json:"description"`
+	Versions    []Version `json:"versions"`
+}
+
+// A registry index, mapping short module names to packages.
+//
+// Registries serve it as index.json, either over HTTP(S) at <url>/index.json
+// or from a local directory containing index.json.
+type Index map[string]Package
+
+// A package found in a registry, along with the registry that listed it.
+type Result struct {
+	Name     string
+	Registry string
+	Package  Package
+}
+
+// A release announced to a registry by ace publish.
+type Release struct {
+	Name     string `json:"name"`
+	Repo     string `json:"repo"`
+	Version  string `json:"version"`
+	Commit   string `json:"commit"`
+	Checksum string `json:"checksum"`
+}
+
+// No configured registry lists the requested module.
+var ErrNotFound = errors.New("not found in any registry")
+
+// Reads and updates registries, keeping each index it loads for later calls.
+type Client struct {
+	// The registries to use, highest priority first. When empty, ACE_REGISTRY
+	// is read instead.
+	Sources []string
+	// How long a registry request may take, no limit when zero.
+	Timeout time.Duration
+
+	indexes map[string]Index
+}
+
+// Get the configured registries from Sources or ACE_REGISTRY, a comma
+// separated list where earlier registries take priority over later ones.
+func (c *Client) Registries() []string {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+
+	var registries []string
+	for reg := range strings.SplitSeq(os.Getenv("ACE_REGISTRY"), ",") {
+		reg = strings.TrimSpace(reg)
+		if reg != "" {
+			registries = append(registries, reg)
+		}
+	}
+	return registries
+}
+
+// Check whether the input is a short registry name rather than a repository URL or path.
+func IsShortName(input string) bool {
+	return input != "" && !strings.ContainsAny(input, "/\\:") && !strings.HasPrefix(input, ".")
+}
+
+// Load the index served by a registry, given its URL or local path.
+func (c *Client) LoadIndex(reg string) (Index, error) {
+	if index, ok := c.indexes[reg]; ok {
+		return index, nil
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if isHTTP(reg) {
+		content, err = c.fetch(strings.TrimSuffix(reg, "/") + "/index.json")
+	} else {
+		content, err = os.ReadFile(localIndexPath(reg))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read registry %s: %v", source.Redact(reg), err)
+	}
+
+	index := make(Index)
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("invalid index from registry %s: %v", source.Redact(reg), err)
+	}
+
+	if c.indexes == nil {
+		c.indexes = make(map[string]Index)
+	}
+	c.indexes[reg] = index
+	return index, nil
+}
+
+// Resolve a short name against the configured registries, in priority order.
+func (c *Client) Resolve(name string) (Result, error) {
+	registries := c.Registries()
+	if len(registries) == 0 {
+		return Result{}, fmt.Errorf("no registries configured, set the registries setting or ACE_REGISTRY to resolve '%s'", name)
+	}
+
+	var lastErr error
+	for _, reg := range registries {
+		index, err := c.LoadIndex(reg)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if pkg, ok := index[name]; ok {
+			return Result{Name: name, Registry: reg, Package: pkg}, nil
+		}
+	}
+
+	if lastErr != nil {
+		return Result{}, fmt.Errorf("module '%s' %w (%v)", name, ErrNotFound, lastErr)
+	}
+	return Result{}, fmt.Errorf("module '%s' %w", name, ErrNotFound)
+}
+
+// Search the configured registries for packages whose name or description
+// contains the term. A name listed by several registries is reported once,
+// from the registry with the highest priority.
+//
+// Registries that cannot be read are skipped, and their errors returned
+// alongside the results.
+func (c *Client) Search(term string) ([]Result, []error, error) {
+	registries := c.Registries()
+	if len(registries) == 0 {
+		return nil, nil, fmt.Errorf("no registries configured, set the registries setting or ACE_REGISTRY")
+	}
+
+	term = strings.ToLower(term)
+	seen := make(map[string]bool)
+	var (
+		results  []Result
+		failures []error
+	)
+
+	for _, reg := range registries {
+		index, err := c.LoadIndex(reg)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+
+		for name, pkg := range index {
+			if seen[name] {
+				continue
+			}
+			if strings.Contains(strings.ToLower(name), term) || strings.Contains(strings.ToLower(pkg.Description), term) {
+				seen[name] = true
+				results = append(results, Result{Name: name, Registry: reg, Package: pkg})
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, failures, nil
+}
+
+// Find a published version of the package, if the registry lists it.
+func (pkg Package) FindVersion(version string) (Version, bool) {
+	for _, v := range pkg.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Find the highest published version, ignoring versions that are not
+// semantic versions.
+func (pkg Package) Latest() (Version, bool) {
+	var (
+		latest  Version
+		highest semver.Version
+		found   bool
+	)
+	for _, v := range pkg.Versions {
+		parsed, ok := semver.Parse(v.Version)
+		if !ok {
+			continue
+		}
+		if !found || semver.Compare(parsed, highest) > 0 {
+			latest, highest, found = v, parsed, true
+		}
+	}
+	return latest, found
+}
+
+// Announce a release to a registry.
+//
+// HTTP(S) registries receive the release as a JSON POST to <url>/publish,
+// while a local registry has its index.json updated in place.
+func (c *Client) Publish(reg string, release Release) error {
+	if isHTTP(reg) {
+		body, err := json.Marshal(release)
+		if err != nil {
+			return err
+		}
+
+		url := strings.TrimSuffix(reg, "/") + "/publish"
+		client := http.Client{Timeout: c.Timeout}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("%s", source.Redact(err.Error()))
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("POST %s: %s", source.Redact(url), resp.Status)
+		}
+		return nil
+	}
+
+	path := localIndexPath(reg)
+	index := make(Index)
+	if content, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(content, &index); err != nil {
+			return fmt.Errorf("invalid index in %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	pkg := index[release.Name]
+	pkg.Repo = release.Repo
+	version := Version{Version: release.Version, Commit: release.Commit, Checksum: release.Checksum}
+	if i := slices.IndexFunc(pkg.Versions, func(v Version) bool { return v.Version == release.Version }); i != -1 {
+		pkg.Versions[i] = version
+	} else {
+		pkg.Versions = append(pkg.Versions, version)
+	}
+	index[release.Name] = pkg
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	delete(c.indexes, reg)
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+func isHTTP(reg string) bool {
+	return strings.HasPrefix(reg, "http://") || strings.HasPrefix(reg, "https://")
+}
+
+func localIndexPath(reg string) string {
+	path := strings.TrimPrefix(reg, "file://")
+	if strings.HasSuffix(path, ".json") {
+		return path
+	}
+	return filepath.Join(path, "index.json")
+}
+
+func (c *Client) fetch(url string) ([]byte, error) {
+	client := http.Client{Timeout: c.Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("%s", source.Redact(err.Error()))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", source.Redact(url), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
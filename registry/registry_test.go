@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func serveIndex(t *testing.T, index string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(index))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSearch(t *testing.T) {
+	primary := serveIndex(t, `{
+		"json": {"repo": "https://github.com/acidlang/json", "description": "JSON encoding"},
+		"yaml": {"repo": "https://github.com/acidlang/yaml", "description": "YAML, a superset of JSON"}
+	}`)
+	secondary := t.TempDir()
+	os.WriteFile(filepath.Join(secondary, "index.json"), []byte(`{
+		"json": {"repo": "https://example.org/fork/json", "description": "A JSON fork"},
+		"jsonpath": {"repo": "https://example.org/jsonpath", "description": "Queries"}
+	}`), 0644)
+	client := &Client{Sources: []string{primary.URL, secondary}}
+
+	results, failures, err := client.Search("JSON")
+	if err != nil || len(failures) != 0 {
+		t.Fatalf("Search = %v, %v", failures, err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	if strings.Join(names, " ") != "json jsonpath yaml" {
+		t.Errorf("Search found %v, want json jsonpath yaml", names)
+	}
+	if results[0].Registry != primary.URL || results[0].Package.Repo != "https://github.com/acidlang/json" {
+		t.Errorf("json came from %s (%s), want the first registry", results[0].Registry, results[0].Package.Repo)
+	}
+}
+
+func TestSearchReportsFailures(t *testing.T) {
+	working := serveIndex(t, `{"json": {"repo": "https://github.com/acidlang/json"}}`)
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+	brokenURL := strings.Replace(broken.URL, "://", "://user:secret@", 1)
+	client := &Client{Sources: []string{brokenURL, working.URL}}
+
+	results, failures, err := client.Search("json")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "json" {
+		t.Errorf("Search = %+v, want json from the working registry", results)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("failures = %v, want one", failures)
+	}
+	if message := failures[0].Error(); strings.Contains(message, "secret") || !strings.Contains(message, "404") {
+		t.Errorf("failure = %q, want a redacted 404", message)
+	}
+}
+
+func TestSearchWithoutRegistries(t *testing.T) {
+	client := &Client{}
+	t.Setenv("ACE_REGISTRY", "")
+	if _, _, err := client.Search("json"); err == nil {
+		t.Errorf("Search without registries succeeded")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	first := serveIndex(t, `{"json": {"repo": "https://github.com/acidlang/json", "versions": [{"version": "v1.0.0", "commit": "abc"}]}}`)
+	second := serveIndex(t, `{"json": {"repo": "https://example.org/json"}, "yaml": {"repo": "https://example.org/yaml"}}`)
+	client := &Client{Sources: []string{first.URL, second.URL}}
+
+	result, err := client.Resolve("json")
+	if err != nil || result.Registry != first.URL {
+		t.Fatalf("Resolve(json) = %+v, %v; want the first registry", result, err)
+	}
+	if version, ok := result.Package.FindVersion("v1.0.0"); !ok || version.Commit != "abc" {
+		t.Errorf("FindVersion = %+v, %v", version, ok)
+	}
+	if result, err := client.Resolve("yaml"); err != nil || result.Package.Repo != "https://example.org/yaml" {
+		t.Errorf("Resolve(yaml) = %+v, %v", result, err)
+	}
+	if _, err := client.Resolve("toml"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve(toml) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	tests := []struct {
+		versions []string
+		latest   string
+	}{
+		{[]string{"v1.0.0", "v1.10.0", "v1.2.0"}, "v1.10.0"},
+		{[]string{"v2.0.0", "v1.9.9"}, "v2.0.0"},
+		{[]string{"v1.0.0", "v1.0.0-rc.1"}, "v1.0.0"},
+		{[]string{"main", "v0.1.0"}, "v0.1.0"},
+		{[]string{"main"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		var pkg Package
+		for _, v := range test.versions {
+			pkg.Versions = append(pkg.Versions, Version{Version: v})
+		}
+		latest, ok := pkg.Latest()
+		if latest.Version != test.latest || ok != (test.latest != "") {
+			t.Errorf("Latest(%v) = %q, %v; want %q", test.versions, latest.Version, ok, test.latest)
+		}
+	}
+}
+
+func TestPublishLocal(t *testing.T) {
+	dir := t.TempDir()
+	client := &Client{}
+
+	for _, release := range []Release{
+		{Name: "json", Repo: "https://github.com/acidlang/json", Version: "v1.0.0", Commit: "a"},
+		{Name: "json", Repo: "https://github.com/acidlang/json", Version: "v1.1.0", Commit: "b"},
+		{Name: "json", Repo: "https://github.com/acidlang/json", Version: "v1.0.0", Commit: "c"},
+	} {
+		if err := client.Publish(dir, release); err != nil {
+			t.Fatalf("Publish %s: %v", release.Version, err)
+		}
+	}
+
+	index, err := client.LoadIndex(dir)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	versions := index["json"].Versions
+	if len(versions) != 2 || versions[0].Commit != "c" || versions[1].Commit != "b" {
+		t.Errorf("versions = %+v, want v1.0.0 republished at c and v1.1.0", versions)
+	}
+}
+
+func TestIsShortName(t *testing.T) {
+	for input, want := range map[string]bool{
+		"json":                             true,
+		"json-schema":                      true,
+		"":                                 false,
+		"../json":                          false,
+		".json":                            false,
+		"github.com/acidlang/json":         false,
+		"git@github.com:acidlang/json":     false,
+		"https://github.com/acidlang/json": false,
+	} {
+		if got := IsShortName(input); got != want {
+			t.Errorf("IsShortName(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
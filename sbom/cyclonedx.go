@@ -0,0 +1,139 @@
+package sbom
+
+import "strings"
+
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type               string        `json:"type"`
+	BOMRef             string        `json:"bom-ref,omitempty"`
+	Name               string        `json:"name"`
+	Version            string        `json:"version,omitempty"`
+	Author             string        `json:"author,omitempty"`
+	Hashes             []cdxHash     `json:"hashes,omitempty"`
+	Licenses           []cdxLicense  `json:"licenses,omitempty"`
+	PURL               string        `json:"purl,omitempty"`
+	ExternalReferences []cdxRef      `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// Either a single license by ID or an SPDX expression.
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+type cdxRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+func cycloneDX(doc Document) cdxDocument {
+	root := cdxComponentFor(doc.Root, "application")
+	root.Properties = nil
+
+	bom := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + documentUUID(doc),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: timestamp(),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: doc.Tool, Version: doc.ToolVersion}}},
+			Component: root,
+		},
+		Components: []cdxComponent{},
+	}
+
+	dependsOn := []string{}
+	for _, component := range doc.Components {
+		bom.Components = append(bom.Components, cdxComponentFor(component, "library"))
+		dependsOn = append(dependsOn, component.Name)
+	}
+	bom.Dependencies = []cdxDependency{{Ref: root.BOMRef, DependsOn: dependsOn}}
+	for _, component := range doc.Components {
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{Ref: component.Name, DependsOn: []string{}})
+	}
+	return bom
+}
+
+func cdxComponentFor(component Component, kind string) cdxComponent {
+	c := cdxComponent{
+		Type:    kind,
+		BOMRef:  component.Name,
+		Name:    component.Name,
+		Version: component.Version,
+		Author:  component.Author,
+	}
+	if kind == "library" {
+		c.PURL = purl(component)
+	}
+
+	if digest := sha256Hex(component.Checksum); digest != "" {
+		c.Hashes = []cdxHash{{Algorithm: "SHA-256", Content: digest}}
+	}
+
+	switch {
+	case isIdentifier(component.License):
+		c.Licenses = []cdxLicense{{License: &cdxLicenseID{ID: component.License}}}
+	case component.License != "":
+		c.Licenses = []cdxLicense{{Expression: component.License}}
+	}
+
+	if component.Repo != "" {
+		ref := cdxRef{Type: "vcs", URL: component.Repo}
+		if component.Archive {
+			ref.Type = "distribution"
+		}
+		c.ExternalReferences = []cdxRef{ref}
+	}
+
+	if component.Commit != "" {
+		c.Properties = append(c.Properties, cdxProperty{Name: "ace:commit", Value: component.Commit})
+	}
+	if len(component.Tags) > 0 {
+		c.Properties = append(c.Properties, cdxProperty{Name: "ace:tags", Value: strings.Join(component.Tags, ",")})
+	}
+	if component.Checksum != "" {
+		// The hash covers the module's files, not a single download.
+		c.Properties = append(c.Properties, cdxProperty{Name: "ace:checksum", Value: component.Checksum})
+	}
+	return c
+}
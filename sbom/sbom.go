@@ -0,0 +1,142 @@
+// Package sbom writes software bills of materials for a project and the
+// modules it depends on, as CycloneDX 1.5 or SPDX 2.3 JSON documents.
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acidlang/ace/source"
+)
+
+// Output formats.
+const (
+	CycloneDX = "cyclonedx"
+	SPDX      = "spdx"
+)
+
+// A project and its dependencies, as described by acid.lock and each
+// module's module.acidcfg.
+type Document struct {
+	// The project itself.
+	Root Component
+	// Its dependencies, sorted by name.
+	Components []Component
+	// The tool writing the document, e.g. "ace", and its version.
+	Tool        string
+	ToolVersion string
+}
+
+// A module in a bill of materials.
+type Component struct {
+	Name    string
+	Version string
+	Author  string
+	Repo    string
+	// Repo is a .tar.gz or .zip download rather than a git repository.
+	Archive bool
+	Commit  string
+	Tags    []string
+	// SPDX identifier or expression, "" when unknown.
+	License string
+	// "sha256:<hex>" hash of the module's files, see checksum.Dir.
+	Checksum string
+}
+
+// Write a document in the given format, CycloneDX or SPDX.
+func Write(w io.Writer, format string, doc Document) error {
+	var document any
+	switch strings.ToLower(format) {
+	case CycloneDX:
+		document = cycloneDX(doc)
+	case SPDX:
+		document = spdx(doc)
+	default:
+		return fmt.Errorf("unknown SBOM format '%s', expected %s or %s", format, CycloneDX, SPDX)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// Get the time the document is created at: SOURCE_DATE_EPOCH when set, so
+// that release builds can reproduce the same document, or else now.
+func timestamp() string {
+	created := time.Now()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		created = time.Unix(epoch, 0)
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// Derive a UUID from the document's contents, so the same dependencies
+// always get the same serial number.
+func documentUUID(doc Document) string {
+	content, _ := json.Marshal(doc)
+	sum := sha256.Sum256(content)
+	sum[6] = sum[6]&0x0f | 0x50 // version 5 layout, name based
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(sum[:16])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// Get a package URL for a module, identifying it by name and version with
+// its repository and commit as the VCS location.
+func purl(component Component) string {
+	purl := "pkg:generic/" + url.PathEscape(component.Name)
+	if component.Version != "" {
+		purl += "@" + url.PathEscape(component.Version)
+	}
+	if location := vcsLocation(component); location != "" {
+		purl += "?vcs_url=" + url.QueryEscape(location)
+	}
+	return purl
+}
+
+// Get the SPDX style VCS location of a module, "git+<repo>@<commit>".
+func vcsLocation(component Component) string {
+	if component.Repo == "" || component.Archive {
+		return ""
+	}
+	location := source.StripCredentials(component.Repo)
+	if !strings.Contains(location, "://") {
+		// scp-like syntax, user@host:path, as an ssh:// URL. Local paths
+		// have no location anyone else can fetch from.
+		host, path, found := strings.Cut(location, ":")
+		if !found || len(host) < 2 || strings.ContainsAny(host, `/\`) {
+			return ""
+		}
+		location = "ssh://" + host + "/" + path
+	}
+	scheme, _, _ := strings.Cut(location, "://")
+	if scheme != "https" && scheme != "http" && scheme != "ssh" && scheme != "git" {
+		return ""
+	}
+	location = "git+" + location
+	if component.Commit != "" {
+		location += "@" + component.Commit
+	}
+	return location
+}
+
+// Get the hex digest of a "sha256:<hex>" checksum.
+func sha256Hex(checksum string) string {
+	if hex, ok := strings.CutPrefix(checksum, "sha256:"); ok {
+		return hex
+	}
+	return ""
+}
+
+// Check whether a license is a single identifier rather than an expression.
+func isIdentifier(license string) bool {
+	return license != "" && !strings.ContainsAny(license, " ()")
+}
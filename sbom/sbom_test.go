@@ -0,0 +1,169 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testDocument() Document {
+	return Document{
+		Root: Component{Name: "app", Version: "0.1.0", Author: "Ada", License: "MIT"},
+		Components: []Component{
+			{
+				Name:     "json",
+				Version:  "v1.2.0",
+				Repo:     "https://github.com/acidlang/json",
+				Commit:   "e0b9fc6c",
+				Tags:     []string{"v1.2.0"},
+				License:  "Apache-2.0",
+				Checksum: "sha256:abcd",
+			},
+			{Name: "yaml", Repo: "git@github.com:acidlang/yaml.git", License: "MIT OR Apache-2.0"},
+			{Name: "toml", Version: "1.0.0", Repo: "https://example.org/toml-1.0.0.tar.gz", Archive: true},
+			{Name: "local", Repo: "../local"},
+		},
+		Tool:        "ace",
+		ToolVersion: "v0.1.1",
+	}
+}
+
+func write(t *testing.T, format string, doc Document) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, format, doc); err != nil {
+		t.Fatalf("Write(%s): %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCycloneDX(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "0")
+	var bom cdxDocument
+	if err := json.Unmarshal(write(t, "CycloneDX", testDocument()), &bom); err != nil {
+		t.Fatal(err)
+	}
+
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" || bom.Metadata.Timestamp != "1970-01-01T00:00:00Z" {
+		t.Errorf("header = %s %s %s", bom.BOMFormat, bom.SpecVersion, bom.Metadata.Timestamp)
+	}
+	if bom.Metadata.Component.Name != "app" || bom.Metadata.Component.Type != "application" {
+		t.Errorf("metadata component = %+v, want the project", bom.Metadata.Component)
+	}
+
+	tests := []struct {
+		name    string
+		purl    string
+		license string
+		ref     string
+		hashes  int
+	}{
+		{"json", "pkg:generic/json@v1.2.0?vcs_url=git%2Bhttps%3A%2F%2Fgithub.com%2Facidlang%2Fjson%40e0b9fc6c", "id:Apache-2.0", "vcs", 1},
+		{"yaml", "pkg:generic/yaml?vcs_url=git%2Bssh%3A%2F%2Fgit%40github.com%2Facidlang%2Fyaml.git", "expression:MIT OR Apache-2.0", "vcs", 0},
+		{"toml", "pkg:generic/toml@1.0.0", "", "distribution", 0},
+		{"local", "pkg:generic/local", "", "vcs", 0},
+	}
+	if len(bom.Components) != len(tests) {
+		t.Fatalf("components = %+v, want %d", bom.Components, len(tests))
+	}
+	for i, test := range tests {
+		c := bom.Components[i]
+		license := ""
+		if len(c.Licenses) == 1 && c.Licenses[0].License != nil {
+			license = "id:" + c.Licenses[0].License.ID
+		} else if len(c.Licenses) == 1 {
+			license = "expression:" + c.Licenses[0].Expression
+		}
+		if c.Name != test.name || c.PURL != test.purl || license != test.license || len(c.Hashes) != test.hashes {
+			t.Errorf("component %d = %s %s %s %d hashes, want %+v", i, c.Name, c.PURL, license, len(c.Hashes), test)
+		}
+		if len(c.ExternalReferences) != 1 || c.ExternalReferences[0].Type != test.ref {
+			t.Errorf("%s references = %+v, want one %s", c.Name, c.ExternalReferences, test.ref)
+		}
+	}
+
+	if len(bom.Dependencies) != 5 || strings.Join(bom.Dependencies[0].DependsOn, " ") != "json yaml toml local" {
+		t.Errorf("dependencies = %+v, want the project depending on every module", bom.Dependencies)
+	}
+}
+
+func TestSPDX(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "0")
+	var document spdxDocument
+	if err := json.Unmarshal(write(t, "spdx", testDocument()), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if document.SPDXVersion != "SPDX-2.3" || document.CreationInfo.Created != "1970-01-01T00:00:00Z" || document.CreationInfo.Creators[0] != "Tool: ace-v0.1.1" {
+		t.Errorf("header = %+v", document)
+	}
+
+	tests := []struct {
+		name     string
+		location string
+		license  string
+		supplier string
+	}{
+		{"app", noAssertion, "MIT", "Person: Ada"},
+		{"json", "git+https://github.com/acidlang/json@e0b9fc6c", "Apache-2.0", ""},
+		{"yaml", "git+ssh://git@github.com/acidlang/yaml.git", "MIT OR Apache-2.0", ""},
+		{"toml", "https://example.org/toml-1.0.0.tar.gz", noAssertion, ""},
+		{"local", noAssertion, noAssertion, ""},
+	}
+	if len(document.Packages) != len(tests) {
+		t.Fatalf("packages = %+v, want %d", document.Packages, len(tests))
+	}
+	for i, test := range tests {
+		pkg := document.Packages[i]
+		if pkg.Name != test.name || pkg.DownloadLocation != test.location || pkg.LicenseDeclared != test.license || pkg.Supplier != test.supplier {
+			t.Errorf("package %d = %s %s %s %s, want %+v", i, pkg.Name, pkg.DownloadLocation, pkg.LicenseDeclared, pkg.Supplier, test)
+		}
+	}
+	if pkg := document.Packages[1]; len(pkg.Checksums) != 1 || pkg.Checksums[0].Value != "abcd" {
+		t.Errorf("json checksums = %+v", pkg.Checksums)
+	}
+
+	if len(document.Relationships) != 5 || document.Relationships[0].Type != "DESCRIBES" || document.Relationships[4].Type != "DEPENDS_ON" {
+		t.Errorf("relationships = %+v", document.Relationships)
+	}
+}
+
+func TestWriteIsReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	for _, format := range []string{CycloneDX, SPDX} {
+		if first, second := write(t, format, testDocument()), write(t, format, testDocument()); !bytes.Equal(first, second) {
+			t.Errorf("%s documents differ between runs", format)
+		}
+	}
+
+	changed := testDocument()
+	changed.Components[0].Commit = "0000000"
+	if documentUUID(changed) == documentUUID(testDocument()) {
+		t.Errorf("different dependencies got the same serial number")
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "xml", testDocument()); err == nil {
+		t.Errorf("Write(xml) succeeded")
+	}
+}
+
+func TestSPDXIDs(t *testing.T) {
+	taken := make(map[string]bool)
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"Package-json", "SPDXRef-Package-json"},
+		{"Package-a_b/c", "SPDXRef-Package-a-b-c"},
+		{"Package-a-b-c", "SPDXRef-Package-a-b-c-2"},
+		{"Package-json", "SPDXRef-Package-json-2"},
+	}
+	for _, test := range tests {
+		if got := spdxID(test.name, taken); got != test.id {
+			t.Errorf("spdxID(%q) = %q, want %q", test.name, got, test.id)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sbom
+
+import (
+	"fmt"
+	"strings"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string         `json:"name"`
+	SPDXID           string         `json:"SPDXID"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	Supplier         string         `json:"supplier,omitempty"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	ExternalRefs     []spdxRef      `json:"externalRefs,omitempty"`
+	Comment          string         `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+const noAssertion = "NOASSERTION"
+
+func spdx(doc Document) spdxDocument {
+	ids := make(map[string]bool)
+	rootID := spdxID("Package-"+doc.Root.Name, ids)
+
+	document := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              doc.Root.Name,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + doc.Root.Name + "-" + documentUUID(doc),
+		CreationInfo: spdxCreationInfo{
+			Created:  timestamp(),
+			Creators: []string{"Tool: " + doc.Tool + "-" + doc.ToolVersion},
+		},
+		Packages: []spdxPackage{spdxPackageFor(doc.Root, rootID)},
+		Relationships: []spdxRelationship{
+			{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: rootID},
+		},
+	}
+
+	for _, component := range doc.Components {
+		id := spdxID("Package-"+component.Name, ids)
+		pkg := spdxPackageFor(component, id)
+		if locator := purl(component); locator != "" {
+			pkg.ExternalRefs = []spdxRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: locator}}
+		}
+		document.Packages = append(document.Packages, pkg)
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: rootID, Type: "DEPENDS_ON", Related: id})
+	}
+	return document
+}
+
+func spdxPackageFor(component Component, id string) spdxPackage {
+	pkg := spdxPackage{
+		Name:             component.Name,
+		SPDXID:           id,
+		VersionInfo:      component.Version,
+		DownloadLocation: noAssertion,
+		LicenseConcluded: noAssertion,
+		LicenseDeclared:  noAssertion,
+		CopyrightText:    noAssertion,
+	}
+
+	if component.Author != "" {
+		pkg.Supplier = "Person: " + component.Author
+	}
+	if location := vcsLocation(component); location != "" {
+		pkg.DownloadLocation = location
+	} else if component.Archive {
+		pkg.DownloadLocation = component.Repo
+	}
+	if component.License != "" {
+		pkg.LicenseDeclared = component.License
+	}
+	if digest := sha256Hex(component.Checksum); digest != "" {
+		pkg.Checksums = []spdxChecksum{{Algorithm: "SHA256", Value: digest}}
+	}
+
+	var notes []string
+	if component.Commit != "" {
+		notes = append(notes, "commit "+component.Commit)
+	}
+	if len(component.Tags) > 0 {
+		notes = append(notes, "tags "+strings.Join(component.Tags, ", "))
+	}
+	if component.Checksum != "" {
+		notes = append(notes, "checksum covers the module's files as hashed by ace")
+	}
+	pkg.Comment = strings.Join(notes, "; ")
+	return pkg
+}
+
+// Make an SPDX element ID from a name: letters, digits, '.' and '-' only,
+// unique within the document.
+func spdxID(name string, taken map[string]bool) string {
+	id := "SPDXRef-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+
+	unique := id
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	taken[unique] = true
+	return unique
+}
@@ -0,0 +1,134 @@
+package semver
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A parsed semantic version, e.g. 1.2.3-rc.1+build.5.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Build      string
+}
+
+// Parse a semantic version, with or without a leading "v".
+func Parse(version string) (Version, bool) {
+	var v Version
+
+	version = strings.TrimPrefix(version, "v")
+	if idx := strings.Index(version, "+"); idx != -1 {
+		v.Build = version[idx+1:]
+		version = version[:idx]
+		if !validIdentifiers(v.Build) {
+			return v, false
+		}
+	}
+	if idx := strings.Index(version, "-"); idx != -1 {
+		v.Prerelease = version[idx+1:]
+		version = version[:idx]
+		if !validIdentifiers(v.Prerelease) {
+			return v, false
+		}
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		// Atoi alone would accept signs, as in "1.+2.3".
+		if part == "" || (len(part) > 1 && part[0] == '0') || strings.Trim(part, "0123456789") != "" {
+			return v, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, false
+		}
+		numbers[i] = n
+	}
+
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+	return v, true
+}
+
+// Check whether a string is a valid semantic version.
+func Valid(version string) bool {
+	_, ok := Parse(version)
+	return ok
+}
+
+func validIdentifiers(s string) bool {
+	if s == "" {
+		return false
+	}
+	for ident := range strings.SplitSeq(s, ".") {
+		if ident == "" {
+			return false
+		}
+		for _, r := range ident {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Compare two versions by semantic versioning precedence, returning -1, 0 or
+// +1. Build metadata is ignored, and a prerelease sorts before its release.
+func Compare(a, b Version) int {
+	switch {
+	case a.Major != b.Major:
+		return sign(a.Major - b.Major)
+	case a.Minor != b.Minor:
+		return sign(a.Minor - b.Minor)
+	case a.Patch != b.Patch:
+		return sign(a.Patch - b.Patch)
+	case a.Prerelease == b.Prerelease:
+		return 0
+	case a.Prerelease == "":
+		return 1
+	case b.Prerelease == "":
+		return -1
+	}
+
+	left := strings.Split(a.Prerelease, ".")
+	right := strings.Split(b.Prerelease, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		if c := compareIdentifier(left[i], right[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(left) - len(right))
+}
+
+// Compare prerelease identifiers: numeric ones numerically and below
+// alphanumeric ones, which compare in ASCII order.
+func compareIdentifier(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(x - y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
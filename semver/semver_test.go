@@ -0,0 +1,86 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Version
+	}{
+		{"1.2.3", Version{Major: 1, Minor: 2, Patch: 3}},
+		{"v0.10.0", Version{Minor: 10}},
+		{"1.0.0-rc.1", Version{Major: 1, Prerelease: "rc.1"}},
+		{"1.0.0-rc.1+build.5", Version{Major: 1, Prerelease: "rc.1", Build: "build.5"}},
+		{"1.0.0+build-5", Version{Major: 1, Build: "build-5"}},
+	}
+	for _, test := range tests {
+		got, ok := Parse(test.version)
+		if !ok || got != test.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", test.version, got, ok, test.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, version := range []string{
+		"",
+		"1",
+		"1.2",
+		"1.2.3.4",
+		"01.2.3",
+		"1.02.3",
+		"1.+2.3",
+		"1.-0.3",
+		"+1.2.3",
+		"1.2.3-",
+		"1.2.3+",
+		"1.2.3-rc..1",
+		"1.2.3-rc_1",
+		"1.2. 3",
+		"a.b.c",
+		"main",
+	} {
+		if v, ok := Parse(version); ok {
+			t.Errorf("Parse(%q) = %+v, want invalid", version, v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// In increasing order of precedence.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := Parse(ordered[i])
+			b, _ := Parse(ordered[j])
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	a, _ := Parse("1.0.0+build.1")
+	b, _ := Parse("1.0.0+build.2")
+	if Compare(a, b) != 0 {
+		t.Errorf("build metadata affects precedence")
+	}
+}